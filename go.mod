@@ -2,7 +2,12 @@ module main
 
 go 1.25.1
 
-require github.com/firebase/genkit/go v1.0.5
+require (
+	github.com/firebase/genkit/go v1.0.5
+	golang.org/x/net v0.41.0
+	golang.org/x/text v0.27.0
+	google.golang.org/genai v1.24.0
+)
 
 require (
 	cloud.google.com/go v0.120.0 // indirect
@@ -36,10 +41,7 @@ require (
 	go.opentelemetry.io/otel/sdk v1.36.0 // indirect
 	go.opentelemetry.io/otel/trace v1.36.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
-	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
-	google.golang.org/genai v1.24.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/grpc v1.73.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
@@ -0,0 +1,149 @@
+// Package labels reads and writes the gender labels of names, kept as a
+// newline separated list of names per gender such as male.txt, either in
+// a run directory of the labeler or in labels/.
+package labels
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"main/internal/normalize"
+)
+
+// Genders are the labels having a file of their own
+var Genders = []string{"male", "female", "unisex", "unknown"}
+
+// Labels are the genders by the canonical form of the names
+type Labels map[string]string
+
+// Read reads the files of the genders in the directory. Missing files are
+// skipped, as older runs don't have the unisex and unknown files. A name
+// listed in more than one file keeps the label of the first of [Genders].
+func Read(dir string) (Labels, error) {
+	l := Labels{}
+	for _, gender := range Genders {
+		names, err := readList(filepath.Join(dir, gender+".txt"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", gender, err)
+		}
+		for _, name := range names {
+			if _, ok := l[name]; !ok {
+				l[name] = gender
+			}
+		}
+	}
+	return l, nil
+}
+
+// ReadFiles reads the labels of the male and female lists given by path,
+// such as labels/male.txt and labels/female.txt. A name in both of the
+// lists is labeled unisex.
+func ReadFiles(male, female string) (Labels, error) {
+	l := Labels{}
+	for _, f := range []struct{ gender, path string }{{"male", male}, {"female", female}} {
+		names, err := readList(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f.gender, err)
+		}
+		for _, name := range names {
+			if g, ok := l[name]; ok && g != f.gender {
+				l[name] = "unisex"
+			} else {
+				l[name] = f.gender
+			}
+		}
+	}
+	return l, nil
+}
+
+func readList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	names := []string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if name := normalize.Name(s.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return names, nil
+}
+
+// Names are sorted alphabetically
+func (l Labels) Names() []string {
+	return slices.Sorted(maps.Keys(l))
+}
+
+// Write writes the files of [Genders] into the directory, each sorted
+// alphabetically
+func (l Labels) Write(dir string) error {
+	for _, gender := range Genders {
+		b := strings.Builder{}
+		for _, name := range l.Names() {
+			if l[name] == gender {
+				fmt.Fprintln(&b, name)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, gender+".txt"), []byte(b.String()), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", gender, err)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the label of the name, falling back to the first word for
+// the compounds without a label of their own
+func (l Labels) Lookup(name string) (string, bool) {
+	if gender, ok := l[name]; ok {
+		return gender, true
+	}
+	if first, _, ok := strings.Cut(name, " "); ok {
+		gender, ok := l[first]
+		return gender, ok
+	}
+	return "", false
+}
+
+// Tally counts the members by their labels. Members of unisex, unknown or
+// missing names are excluded from the ratio.
+type Tally struct {
+	Male     int `json:"male"`
+	Female   int `json:"female"`
+	Excluded int `json:"excluded"`
+}
+
+func (t *Tally) Add(gender string, n int) {
+	switch gender {
+	case "male":
+		t.Male += n
+	case "female":
+		t.Female += n
+	default:
+		t.Excluded += n
+	}
+}
+
+// Tally counts the members having the names
+func (l Labels) Tally(names []string) Tally {
+	t := Tally{}
+	for _, name := range names {
+		gender, _ := l.Lookup(name)
+		t.Add(gender, 1)
+	}
+	return t
+}
@@ -0,0 +1,90 @@
+package labels
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"main/internal/normalize"
+)
+
+// Override is a label fixed by hand, which wins over the labels of the
+// model wherever the labels are used
+type Override struct {
+	Name   string
+	Gender string
+	Note   string // why the model was wrong
+	Author string
+}
+
+// Overrides are by the canonical form of the names
+type Overrides map[string]Override
+
+// ReadOverrides reads the tab separated table with the columns of name,
+// gender, note and author, such as labels/overrides.tsv. The header is
+// required, blank lines and the lines starting with "#" are skipped. A
+// missing file has no overrides.
+func ReadOverrides(path string) (Overrides, error) {
+	o := Overrides{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		return o, s.Err()
+	}
+	if !strings.HasPrefix(s.Text(), "name\tgender") {
+		return nil, fmt.Errorf("unexpected header: %q", s.Text())
+	}
+	for line := 2; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" || strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected name and gender", line)
+		}
+		fields = append(fields, "", "")
+		ov := Override{
+			Name:   normalize.Name(fields[0]),
+			Gender: strings.TrimSpace(fields[1]),
+			Note:   strings.TrimSpace(fields[2]),
+			Author: strings.TrimSpace(fields[3]),
+		}
+		if ov.Name == "" {
+			return nil, fmt.Errorf("line %d: empty name", line)
+		}
+		if !slices.Contains(Genders, ov.Gender) {
+			return nil, fmt.Errorf("line %d: unexpected gender: %q", line, ov.Gender)
+		}
+		if _, ok := o[ov.Name]; ok {
+			return nil, fmt.Errorf("line %d: %q is overridden more than once", line, ov.Name)
+		}
+		o[ov.Name] = ov
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return o, nil
+}
+
+// Apply sets the labels of the overridden names, and returns how many of
+// them were labeled differently
+func (l Labels) Apply(o Overrides) int {
+	changed := 0
+	for name, ov := range o {
+		if l[name] != ov.Gender {
+			changed++
+		}
+		l[name] = ov.Gender
+	}
+	return changed
+}
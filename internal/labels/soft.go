@@ -0,0 +1,110 @@
+package labels
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"main/internal/normalize"
+)
+
+// Soft are the probabilities of the names being female, by the canonical
+// form of the names. Names labeled unknown have none.
+type Soft map[string]float64
+
+// PFemale is the probability of a hard label being female, for the labels
+// fixed by hand
+func PFemale(gender string) (float64, bool) {
+	switch gender {
+	case "female":
+		return 1, true
+	case "male":
+		return 0, true
+	case "unisex":
+		return 0.5, true
+	}
+	return 0, false
+}
+
+// ReadSoft reads the tab separated "<name>\t<probability>" lines, such as
+// the soft.tsv of a run
+func ReadSoft(path string) (Soft, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	soft := Soft{}
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" || strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		name, p, ok := strings.Cut(s.Text(), "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected name and probability", line)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 || v > 1 {
+			return nil, fmt.Errorf("line %d: expected a probability: %q", line, p)
+		}
+		soft[normalize.Name(name)] = v
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return soft, nil
+}
+
+// ReadSoftIfExists is [ReadSoft] giving no probabilities for a missing
+// file, as the runs before the soft labels don't have one
+func ReadSoftIfExists(path string) (Soft, error) {
+	s, err := ReadSoft(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Soft{}, nil
+	}
+	return s, err
+}
+
+// Write writes the probabilities sorted by name
+func (s Soft) Write(path string) error {
+	b := strings.Builder{}
+	for _, name := range slices.Sorted(maps.Keys(s)) {
+		fmt.Fprintf(&b, "%s\t%s\n", name, strconv.FormatFloat(s[name], 'g', 4, 64))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the probability of the name, falling back to the first
+// word for the compounds without a probability of their own
+func (s Soft) Lookup(name string) (float64, bool) {
+	if p, ok := s[name]; ok {
+		return p, true
+	}
+	if first, _, ok := strings.Cut(name, " "); ok {
+		p, ok := s[first]
+		return p, ok
+	}
+	return 0, false
+}
+
+// Apply replaces the probabilities of the overridden names by the ones of
+// their labels
+func (s Soft) Apply(o Overrides) {
+	for name, ov := range o {
+		if p, ok := PFemale(ov.Gender); ok {
+			s[name] = p
+		} else {
+			delete(s, name)
+		}
+	}
+}
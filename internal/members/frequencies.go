@@ -0,0 +1,112 @@
+package members
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Frequencies counts the members having each given name, in total and
+// per community
+type Frequencies struct {
+	Communities []string
+	Counts      map[string]map[string]int // by name and community
+}
+
+func NewFrequencies() *Frequencies {
+	return &Frequencies{Counts: map[string]map[string]int{}}
+}
+
+func (f *Frequencies) Add(community string, names []string) {
+	if !slices.Contains(f.Communities, community) {
+		f.Communities = append(f.Communities, community)
+		slices.Sort(f.Communities)
+	}
+	for _, name := range names {
+		if f.Counts[name] == nil {
+			f.Counts[name] = map[string]int{}
+		}
+		f.Counts[name][community]++
+	}
+}
+
+// Names are sorted alphabetically
+func (f *Frequencies) Names() []string {
+	return slices.Sorted(maps.Keys(f.Counts))
+}
+
+func (f *Frequencies) Total(name string) int {
+	total := 0
+	for _, c := range f.Counts[name] {
+		total += c
+	}
+	return total
+}
+
+// Write writes a tab separated table with a row for each name, and the
+// columns of the total and of each community
+func (f *Frequencies) Write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "name\ttotal\t%s\n", strings.Join(f.Communities, "\t"))
+	for _, name := range f.Names() {
+		fmt.Fprintf(w, "%s\t%d", name, f.Total(name))
+		for _, c := range f.Communities {
+			fmt.Fprintf(w, "\t%d", f.Counts[name][c])
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return file.Close()
+}
+
+func ReadFrequencies(path string) (*Frequencies, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer file.Close()
+
+	f := NewFrequencies()
+	s := bufio.NewScanner(file)
+	if !s.Scan() {
+		return nil, fmt.Errorf("missing header")
+	}
+	header := strings.Split(s.Text(), "\t")
+	if len(header) < 2 || header[0] != "name" || header[1] != "total" {
+		return nil, fmt.Errorf("unexpected header: %q", s.Text())
+	}
+	f.Communities = header[2:]
+
+	for line := 2; s.Scan(); line++ {
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) != len(header) {
+			return nil, fmt.Errorf("line %d: expected %d columns, got %d", line, len(header), len(fields))
+		}
+		f.Counts[fields[0]] = map[string]int{}
+		for i, c := range f.Communities {
+			n, err := strconv.Atoi(fields[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line, c, err)
+			}
+			if n > 0 {
+				f.Counts[fields[0]][c] = n
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return f, nil
+}
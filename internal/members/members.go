@@ -0,0 +1,87 @@
+// Package members reads the member lists of the communities under data/
+// and the name frequencies built from them.
+package members
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/internal/normalize"
+)
+
+// GivenNames is a set of known given names in canonical form, used for
+// telling the second given name of a compound from a surname
+type GivenNames map[string]bool
+
+// ReadGivenNames reads the newline separated lists of names, such as the
+// labels/male.txt and labels/female.txt. Missing files are skipped.
+func ReadGivenNames(paths ...string) (GivenNames, error) {
+	k := GivenNames{}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if name := normalize.Name(line); name != "" {
+				k[name] = true
+			}
+		}
+	}
+	return k, nil
+}
+
+// Compound is the canonical given-name part of the full name: the first
+// word followed by the next words as long as they are known given names,
+// eg. "ayşe nur" for "Ayşe Nur Kaya" when "nur" is known. A nil set gives
+// the first word.
+func (k GivenNames) Compound(fullname string) string {
+	words := strings.Fields(normalize.Name(fullname))
+	if len(words) == 0 {
+		return ""
+	}
+	n := 1
+	for n < len(words) && k[words[n]] {
+		n++
+	}
+	return strings.Join(words[:n], " ")
+}
+
+// Community is the name of the member list, the file name without the
+// extension, eg. "data/goturkiye.txt" is "goturkiye"
+func Community(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// ReadCommunity returns the canonical names of the members in the file
+// containing a member per line, for [GivenNames.Compound] to pick the
+// given names. Blank lines and the comment lines starting with "#", such
+// as the source written by the extract command, are skipped.
+func ReadCommunity(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	names := []string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		if name := normalize.Name(s.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return names, nil
+}
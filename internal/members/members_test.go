@@ -0,0 +1,53 @@
+package members
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompound(t *testing.T) {
+	known := GivenNames{"nur": true, "ali": true, "can": true, "ahmet": true}
+	tests := []struct {
+		known    GivenNames
+		fullname string
+		expected string
+	}{
+		{known, "Ayşe Nur Kaya", "ayşe nur"},
+		{known, "Mehmet Ali", "mehmet ali"},
+		{known, "ahmet kaya", "ahmet"},
+		// a surname that is also a known given name is taken as a part
+		// of the compound
+		{known, "ahmet can yılmaz", "ahmet can"},
+		{known, "Ahmet Can", "ahmet can"},
+		{known, "Nur Ali Can", "nur ali can"},
+		{known, "Kaya Nur", "kaya nur"},
+		{known, "AYŞE  NUR", "ayşe nur"},
+		{known, "Zeynep", "zeynep"},
+		{known, "", ""},
+		{nil, "Ayşe Nur Kaya", "ayşe"},
+	}
+	for _, tt := range tests {
+		if got := tt.known.Compound(tt.fullname); got != tt.expected {
+			t.Errorf("Compound(%q) = %q, expected %q", tt.fullname, got, tt.expected)
+		}
+	}
+}
+
+func TestReadGivenNames(t *testing.T) {
+	dir := t.TempDir()
+	male, female := filepath.Join(dir, "male.txt"), filepath.Join(dir, "female.txt")
+	if err := os.WriteFile(male, []byte("Ahmet\nALİ\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(female, []byte("Nur\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := ReadGivenNames(male, female, filepath.Join(dir, "missing.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k) != 3 || !k["ahmet"] || !k["ali"] || !k["nur"] {
+		t.Errorf("expected the canonical names of both lists, got %v", k)
+	}
+}
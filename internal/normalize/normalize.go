@@ -0,0 +1,35 @@
+// Package normalize gives the canonical form of member names. Every stage
+// that compares names (ingest, labeling, the cache and the ratios) should
+// use [Name] as the key, so the same name always lands in the same bucket.
+package normalize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// invisible characters are dropped anywhere in the name: the zero width
+// (non)joiners, the byte order mark, bidi marks and soft hyphens
+func invisible(r rune) bool {
+	return unicode.Is(unicode.Cf, r)
+}
+
+// Name lowers the name by the Turkish rules (İ→i, I→ı), composes it to
+// NFC and trims the invisible characters and the extra whitespace.
+func Name(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if invisible(r) {
+			return -1
+		}
+		return r
+	}, s)
+	s = norm.NFC.String(s)
+	s = strings.ToLowerSpecial(unicode.TurkishCase, s)
+	// tools lowering İ without the Turkish rules leave a combining dot
+	// above on the i
+	s = strings.ReplaceAll(s, "i\u0307", "i")
+	s = norm.NFC.String(s)
+	return strings.Join(strings.Fields(s), " ")
+}
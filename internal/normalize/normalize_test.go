@@ -0,0 +1,79 @@
+package normalize
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var nameTests = []struct {
+	name, in, expected string
+}{
+	{"turkish upper", "IŞIL", "ışıl"},
+	{"turkish title", "Işıl", "ışıl"},
+	{"dotted capital", "İSMAİL", "ismail"},
+	{"dotted capital lowered without the turkish rules", "i\u0307smai\u0307l", "ismail"},
+	{"decomposed dotted capital", "I\u0307smail", "ismail"},
+	{"composed", "Ay\u015fe", "ayşe"},
+	{"decomposed", "Ays\u0327e", "ayşe"},
+	{"zero width space", "Ay\u200bşe", "ayşe"},
+	{"zero width joiner", "Meh\u200dmet", "mehmet"},
+	{"byte order mark", "\ufeffMehmet", "mehmet"},
+	{"soft hyphen", "Meh\u00admet", "mehmet"},
+	{"whitespace", "  Ayşe \t Nur\n", "ayşe nur"},
+	{"empty", "", ""},
+}
+
+func TestName(t *testing.T) {
+	for _, tt := range nameTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Name(tt.in); got != tt.expected {
+				t.Errorf("Name(%q) = %q, expected %q", tt.in, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestName_python runs the canonical function of the ratio script on the
+// same names, as the two have to be kept in sync by hand
+func TestName_python(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	b, err := os.ReadFile("../../scripts/ratio-for-kommunity.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := regexp.MustCompile(`(?ms)^def canonical\(.*?\n\n`).Find(b)
+	if def == nil {
+		t.Fatal("canonical not found in the ratio script")
+	}
+	program := "import sys, unicodedata\n" + string(def) +
+		"for line in sys.stdin.read().split('\\x00'):\n" +
+		"    print(repr(canonical(line)))\n"
+
+	inputs := []string{}
+	for _, tt := range nameTests {
+		inputs = append(inputs, tt.in)
+	}
+	cmd := exec.Command(python, "-c", program)
+	cmd.Env = append(os.Environ(), "PYTHONIOENCODING=utf-8")
+	cmd.Stdin = strings.NewReader(strings.Join(inputs, "\x00"))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("python3: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(got) != len(nameTests) {
+		t.Fatalf("expected %d names from python3, got %d", len(nameTests), len(got))
+	}
+	for i, tt := range nameTests {
+		// repr of the ascii and the turkish letters is the quoted name
+		if expected := "'" + tt.expected + "'"; got[i] != expected {
+			t.Errorf("%s: canonical(%q) = %s, expected %s", tt.name, tt.in, got[i], expected)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package ratio counts the members of a community by the labels of their
+// names and estimates the M:F ratio.
+package ratio
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"main/internal/labels"
+	"main/internal/members"
+	"main/internal/stats"
+)
+
+// Result is the counts of a community and the estimates from them
+type Result struct {
+	Community string `json:"community"`
+	Members   int    `json:"members"`
+	Included  int    `json:"included"`
+	labels.Tally
+	Level       float64        `json:"level"`
+	FemaleShare stats.Interval `json:"female_share"`
+	Ratio       stats.Interval `json:"ratio"` // males per a female
+
+	Bootstrapped *Bootstrap   `json:"bootstrap,omitempty"`
+	Bounded      *Sensitivity `json:"sensitivity,omitempty"`
+	Expected     *Expected    `json:"soft,omitempty"`
+}
+
+// GivenNames picks the given names of the members as the ingest command
+// does, joining the compounds of the labeled names
+func GivenNames(fullnames []string, l labels.Labels) []string {
+	known := members.GivenNames{}
+	for name, gender := range l {
+		if gender == "male" || gender == "female" {
+			known[name] = true
+		}
+	}
+	names := []string{}
+	for _, n := range fullnames {
+		names = append(names, known.Compound(n))
+	}
+	return names
+}
+
+// ReadCommunity reads the member list and picks the given names by
+// [GivenNames]
+func ReadCommunity(path string, l labels.Labels) ([]string, error) {
+	fullnames, err := members.ReadCommunity(path)
+	if err != nil {
+		return nil, err
+	}
+	return GivenNames(fullnames, l), nil
+}
+
+// Estimate counts the members by the labels of their given names. The
+// interval of the female share is Wilson's at the confidence level.
+func Estimate(community string, names []string, l labels.Labels, level float64) Result {
+	return FromTally(community, l.Tally(names), level)
+}
+
+func FromTally(community string, t labels.Tally, level float64) Result {
+	r := Result{
+		Community: community,
+		Members:   t.Male + t.Female + t.Excluded,
+		Included:  t.Male + t.Female,
+		Tally:     t,
+		Level:     level,
+	}
+	r.FemaleShare = stats.Wilson(t.Female, r.Included, level)
+	r.Ratio = stats.MalePerFemale(r.FemaleShare)
+	return r
+}
+
+// FormatRatio prints the males per a female as the README tables, eg.
+// "5.4 : 1", or "1 : 2.3" for the communities with more females. It is
+// "inf : 1" without a female, "1 : inf" without a male and "-" without
+// a member.
+func FormatRatio(r stats.Float) string {
+	switch {
+	case math.IsNaN(float64(r)):
+		return "-"
+	case math.IsInf(float64(r), 1):
+		return "inf : 1"
+	case r == 0:
+		return "1 : inf"
+	case r < 1:
+		return fmt.Sprintf("1 : %.1f", 1/float64(r))
+	default:
+		return fmt.Sprintf("%.1f : 1", float64(r))
+	}
+}
+
+// FormatShare prints the share as a percentage, or "-" when it is NaN
+func FormatShare(p stats.Float) string {
+	if math.IsNaN(float64(p)) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(p))
+}
+
+// Bootstrap is the percentile intervals of the estimates over the member
+// lists resampled with replacement
+type Bootstrap struct {
+	Iterations  int            `json:"iterations"`
+	Seed        uint64         `json:"seed"`
+	FemaleShare stats.Interval `json:"female_share"`
+	Ratio       stats.Interval `json:"ratio"`
+}
+
+// Bootstrap resamples the members of the community, which only takes their
+// counts as the members are told apart by their labels alone. The value
+// of the intervals is the median of the resamples.
+func (r *Result) Bootstrap(iterations int, seed uint64) {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	shares, ratios := make([]float64, iterations), make([]float64, iterations)
+	for i := range iterations {
+		male, female := 0, 0
+		for range r.Members {
+			switch k := rng.IntN(r.Members); {
+			case k < r.Male:
+				male++
+			case k < r.Male+r.Female:
+				female++
+			}
+		}
+		shares[i], ratios[i] = math.NaN(), math.NaN()
+		if male+female > 0 {
+			shares[i] = float64(female) / float64(male+female)
+			ratios[i] = math.Inf(1)
+		}
+		if female > 0 {
+			ratios[i] = float64(male) / float64(female)
+		}
+	}
+	r.Bootstrapped = &Bootstrap{
+		Iterations:  iterations,
+		Seed:        seed,
+		FemaleShare: stats.Percentiles(shares, r.Level),
+		Ratio:       stats.Percentiles(ratios, r.Level),
+	}
+}
+
+// Scenario is the estimates with the excluded members counted as female
+// at a rate
+type Scenario struct {
+	Name        string      `json:"name"`
+	Rate        stats.Float `json:"rate"` // of the excluded counted as female
+	FemaleShare stats.Float `json:"female_share"`
+	Ratio       stats.Float `json:"ratio"`
+}
+
+// Sensitivity is the estimates under assumptions on the genders of the
+// excluded members. The bounds are the scenarios of all of them being
+// male and all of them being female, around the observed estimate.
+type Sensitivity struct {
+	Scenarios   []Scenario     `json:"scenarios"`
+	FemaleShare stats.Interval `json:"female_share"`
+	Ratio       stats.Interval `json:"ratio"`
+}
+
+func (r Result) scenario(name string, rate float64) Scenario {
+	female := float64(r.Female) + rate*float64(r.Excluded)
+	male := float64(r.Male) + (1-rate)*float64(r.Excluded)
+	s := Scenario{Name: name, Rate: stats.Float(rate), FemaleShare: stats.Float(math.NaN()), Ratio: stats.Float(math.NaN())}
+	if male+female > 0 {
+		s.FemaleShare = stats.Float(female / (male + female))
+		s.Ratio = stats.Float(math.Inf(1))
+	}
+	if female > 0 {
+		s.Ratio = stats.Float(male / female)
+	}
+	return s
+}
+
+// Sensitivity recomputes the estimates with the excluded members counted
+// as all male, all female, and split at the observed female share. A rate
+// between 0 and 1 adds a scenario of splitting them at that rate.
+func (r *Result) Sensitivity(rate float64) {
+	male, female := r.scenario("all male", 0), r.scenario("all female", 1)
+	observed := r.scenario("observed rate", float64(r.FemaleShare.Value))
+	s := &Sensitivity{
+		Scenarios:   []Scenario{male, female, observed},
+		FemaleShare: stats.Interval{Value: r.FemaleShare.Value, Low: male.FemaleShare, High: female.FemaleShare},
+		Ratio:       stats.Interval{Value: r.Ratio.Value, Low: female.Ratio, High: male.Ratio},
+	}
+	if rate >= 0 && rate <= 1 {
+		s.Scenarios = append(s.Scenarios, r.scenario("given rate", rate))
+	}
+	r.Bounded = s
+}
+
+// Expected is the counts of the members as the sums of the probabilities
+// of their names being female. Each name is a coin flip of its own, so
+// the variance of both counts is the sum of p(1-p), which only covers the
+// uncertainty of the labels and not of the sampling of the members.
+type Expected struct {
+	Counted     int            `json:"counted"`   // members with a probability
+	Uncounted   int            `json:"uncounted"` // unknown and missing names
+	Female      stats.Float    `json:"female"`
+	Male        stats.Float    `json:"male"`
+	Variance    stats.Float    `json:"variance"`
+	FemaleShare stats.Interval `json:"female_share"` // with the normal approximation
+	Ratio       stats.Interval `json:"ratio"`
+}
+
+// Soft counts the members by the soft labels of their names, so the unisex
+// names count as a fraction of a female and a male instead of vanishing
+func (r *Result) Soft(names []string, soft labels.Soft) {
+	e := &Expected{}
+	female, variance := 0.0, 0.0
+	for _, name := range names {
+		p, ok := soft.Lookup(name)
+		if !ok {
+			e.Uncounted++
+			continue
+		}
+		e.Counted++
+		female += p
+		variance += p * (1 - p)
+	}
+	e.Female = stats.Float(female)
+	e.Male = stats.Float(float64(e.Counted) - female)
+	e.Variance = stats.Float(variance)
+	e.FemaleShare = stats.Normal(female, variance, e.Counted, r.Level)
+	e.Ratio = stats.MalePerFemale(e.FemaleShare)
+	r.Expected = e
+}
@@ -0,0 +1,140 @@
+package ratio
+
+import (
+	"math"
+	"testing"
+
+	"main/internal/labels"
+	"main/internal/stats"
+)
+
+func TestFromTally(t *testing.T) {
+	tests := []struct {
+		name          string
+		tally         labels.Tally
+		members       int
+		share         float64
+		ratio         float64
+		finiteBounds  bool // of the ratio
+		expectedRatio string
+	}{
+		{"no female", labels.Tally{Male: 10, Excluded: 2}, 12, 0, math.Inf(1), false, "inf : 1"},
+		{"no male", labels.Tally{Female: 10}, 10, 1, 0, true, "1 : inf"},
+		{"no member included", labels.Tally{Excluded: 3}, 3, math.NaN(), math.NaN(), false, "-"},
+		{"no member", labels.Tally{}, 0, math.NaN(), math.NaN(), false, "-"},
+		{"both", labels.Tally{Male: 90, Female: 10, Excluded: 5}, 105, 0.1, 9, true, "9.0 : 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := FromTally("c", tt.tally, 0.95)
+			if r.Members != tt.members || r.Included != tt.tally.Male+tt.tally.Female {
+				t.Errorf("expected %d members, %d included, got %d, %d", tt.members, tt.tally.Male+tt.tally.Female, r.Members, r.Included)
+			}
+			if !same(r.FemaleShare.Value, tt.share) || !same(r.Ratio.Value, tt.ratio) {
+				t.Errorf("expected the share %g and the ratio %g, got %v and %v", tt.share, tt.ratio, r.FemaleShare.Value, r.Ratio.Value)
+			}
+			if got := FormatRatio(r.Ratio.Value); got != tt.expectedRatio {
+				t.Errorf("expected %q, got %q", tt.expectedRatio, got)
+			}
+			if tt.finiteBounds && (math.IsInf(float64(r.Ratio.Low), 0) || math.IsInf(float64(r.Ratio.High), 0)) {
+				t.Errorf("expected finite bounds of the ratio, got %v", r.Ratio)
+			}
+		})
+	}
+}
+
+func TestFormatRatio(t *testing.T) {
+	tests := []struct {
+		ratio    float64
+		expected string
+	}{
+		{5.4, "5.4 : 1"},
+		{1, "1.0 : 1"},
+		{3.0 / 7, "1 : 2.3"},
+		{0, "1 : inf"},
+		{math.Inf(1), "inf : 1"},
+		{math.NaN(), "-"},
+	}
+	for _, tt := range tests {
+		if got := FormatRatio(stats.Float(tt.ratio)); got != tt.expected {
+			t.Errorf("FormatRatio(%g) = %q, expected %q", tt.ratio, got, tt.expected)
+		}
+	}
+}
+
+// the ratio decreases with the share, so its low bound comes from the
+// high bound of the share
+func TestFromTally_bounds(t *testing.T) {
+	r := FromTally("c", labels.Tally{Male: 90, Female: 10}, 0.95)
+	share := r.FemaleShare
+	if !(share.Low < share.Value && share.Value < share.High) {
+		t.Fatalf("expected the share within its bounds, got %v", share)
+	}
+	if !(r.Ratio.Low < r.Ratio.Value && r.Ratio.Value < r.Ratio.High) {
+		t.Errorf("expected the ratio within its bounds, got %v", r.Ratio)
+	}
+	if low := (1 - share.High) / share.High; !same(r.Ratio.Low, float64(low)) {
+		t.Errorf("expected the low ratio %g from the high share, got %v", low, r.Ratio.Low)
+	}
+	if high := (1 - share.Low) / share.Low; !same(r.Ratio.High, float64(high)) {
+		t.Errorf("expected the high ratio %g from the low share, got %v", high, r.Ratio.High)
+	}
+
+	// without a female only the low bound of the ratio is finite
+	r = FromTally("c", labels.Tally{Male: 10}, 0.95)
+	if math.IsInf(float64(r.Ratio.Low), 0) || !math.IsInf(float64(r.Ratio.High), 1) {
+		t.Errorf("expected a finite low and an infinite high ratio, got %v", r.Ratio)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	l := labels.Labels{"ahmet": "male", "mehmet": "male", "ayşe": "female", "deniz": "unisex"}
+	// the compounds without a label fall back to their first word
+	r := Estimate("c", []string{"ahmet", "mehmet ali", "ayşe", "deniz", "xyz"}, l, 0.95)
+	expected := labels.Tally{Male: 2, Female: 1, Excluded: 2}
+	if r.Tally != expected || r.Members != 5 || r.Included != 3 {
+		t.Errorf("expected %+v of 5 members, got %+v of %d", expected, r.Tally, r.Members)
+	}
+}
+
+func TestSensitivity(t *testing.T) {
+	r := FromTally("c", labels.Tally{Male: 6, Female: 2, Excluded: 2}, 0.95)
+	r.Sensitivity(0.5)
+	s := r.Bounded
+	if len(s.Scenarios) != 4 {
+		t.Fatalf("expected 4 scenarios with the given rate, got %d", len(s.Scenarios))
+	}
+	// all male: 8 to 2, all female: 6 to 4
+	if !same(s.Ratio.Low, 1.5) || !same(s.Ratio.High, 4) {
+		t.Errorf("expected the ratio bounds 1.5 – 4, got %v", s.Ratio)
+	}
+	if !same(s.FemaleShare.Low, 0.2) || !same(s.FemaleShare.High, 0.4) {
+		t.Errorf("expected the share bounds 0.2 – 0.4, got %v", s.FemaleShare)
+	}
+	if given := s.Scenarios[3]; !same(given.Ratio, 7.0/3) {
+		t.Errorf("expected the ratio 7:3 at the given rate, got %v", given.Ratio)
+	}
+}
+
+func TestBootstrap_seed(t *testing.T) {
+	a := FromTally("c", labels.Tally{Male: 40, Female: 10, Excluded: 5}, 0.95)
+	b := a
+	a.Bootstrap(200, 7)
+	b.Bootstrap(200, 7)
+	if *a.Bootstrapped != *b.Bootstrapped {
+		t.Errorf("expected the same intervals from the same seed, got %v and %v", a.Bootstrapped, b.Bootstrapped)
+	}
+	if share := a.Bootstrapped.FemaleShare; !(share.Low <= 0.2 && 0.2 <= share.High) {
+		t.Errorf("expected the share 0.2 within the percentiles, got %v", share)
+	}
+}
+
+func same(got stats.Float, expected float64) bool {
+	if math.IsNaN(expected) {
+		return math.IsNaN(float64(got))
+	}
+	if math.IsInf(expected, 0) {
+		return float64(got) == expected
+	}
+	return math.Abs(float64(got)-expected) < 1e-9
+}
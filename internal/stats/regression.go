@@ -0,0 +1,214 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// weights are all 1 when w is nil
+func weight(w []float64, i int) float64 {
+	if w == nil {
+		return 1
+	}
+	return w[i]
+}
+
+func mean(xs, w []float64) float64 {
+	sum, ws := 0.0, 0.0
+	for i, x := range xs {
+		sum += weight(w, i) * x
+		ws += weight(w, i)
+	}
+	return sum / ws
+}
+
+// Pearson is the weighted correlation of x and y, NaN when either of them
+// is constant
+func Pearson(x, y, w []float64) float64 {
+	mx, my := mean(x, w), mean(y, w)
+	sxy, sxx, syy := 0.0, 0.0, 0.0
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += weight(w, i) * dx * dy
+		sxx += weight(w, i) * dx * dx
+		syy += weight(w, i) * dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// ranks are from 1, ties get the average of their ranks
+func ranks(xs []float64) []float64 {
+	order := make([]int, len(xs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case xs[a] < xs[b]:
+			return -1
+		case xs[a] > xs[b]:
+			return 1
+		}
+		return 0
+	})
+	r := make([]float64, len(xs))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && xs[order[j+1]] == xs[order[i]] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			r[order[k]] = float64(i+j)/2 + 1
+		}
+		i = j + 1
+	}
+	return r
+}
+
+// Spearman is the weighted correlation of the ranks of x and y
+func Spearman(x, y, w []float64) float64 {
+	return Pearson(ranks(x), ranks(y), w)
+}
+
+// Fit is the weighted least squares line of y on x
+type Fit struct {
+	Intercept Float    `json:"intercept"`
+	Slope     Interval `json:"slope"`
+	SlopeSE   Float    `json:"slope_se"`
+	R2        Float    `json:"r2"`
+}
+
+// OLS fits the line with the interval of the slope from Student's t
+// distribution with n-2 degrees of freedom. Weights are taken as the
+// inverse variances of the points up to a common factor, so scaling all
+// of them doesn't change the fit or the interval.
+func OLS(x, y, w []float64, level float64) Fit {
+	n := len(x)
+	if n < 3 {
+		f := Float(math.NaN())
+		return Fit{f, nan(), f, f}
+	}
+	mx, my := mean(x, w), mean(y, w)
+	sxy, sxx, syy := 0.0, 0.0, 0.0
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += weight(w, i) * dx * dy
+		sxx += weight(w, i) * dx * dx
+		syy += weight(w, i) * dy * dy
+	}
+	slope := sxy / sxx
+	intercept := my - slope*mx
+
+	sse := 0.0
+	for i := range x {
+		e := y[i] - intercept - slope*x[i]
+		sse += weight(w, i) * e * e
+	}
+	se := math.Sqrt(sse / float64(n-2) / sxx)
+	t := StudentQuantile((1+level)/2, float64(n-2))
+	return Fit{
+		Intercept: Float(intercept),
+		Slope:     Interval{Float(slope), Float(slope - t*se), Float(slope + t*se)},
+		SlopeSE:   Float(se),
+		R2:        Float(1 - sse/syy),
+	}
+}
+
+// Permutation is the two sided p-value of the correlation by shuffling y
+// against x and the weights, counting the shuffles with a correlation at
+// least as strong as the observed one. The observed order is counted as
+// one of the shuffles, so the p-value is never 0.
+func Permutation(x, y, w []float64, corr func(x, y, w []float64) float64, iterations int, seed uint64) float64 {
+	observed := math.Abs(corr(x, y, w))
+	if math.IsNaN(observed) {
+		return math.NaN()
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+	shuffled := slices.Clone(y)
+	extreme := 0
+	for range iterations {
+		rng.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		// rounding can make the observed order a hair weaker
+		if math.Abs(corr(x, shuffled, w)) >= observed-1e-12 {
+			extreme++
+		}
+	}
+	return float64(extreme+1) / float64(iterations+1)
+}
+
+// StudentQuantile is the p quantile of Student's t distribution with df
+// degrees of freedom, found by bisection on its distribution function
+func StudentQuantile(p, df float64) float64 {
+	cdf := func(t float64) float64 {
+		tail := 0.5 * incompleteBeta(df/2, 0.5, df/(df+t*t))
+		if t < 0 {
+			return tail
+		}
+		return 1 - tail
+	}
+	lo, hi := -1e3, 1e3
+	for range 200 {
+		mid := (lo + hi) / 2
+		if cdf(mid) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// incompleteBeta is the regularized incomplete beta function I_x(a, b),
+// evaluated by its continued fraction
+func incompleteBeta(a, b, x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	case x > (a+1)/(a+b+2):
+		// the continued fraction converges fast only below the mean
+		return 1 - incompleteBeta(b, a, 1-x)
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab-la-lb+a*math.Log(x)+b*math.Log(1-x)) / a
+
+	// Lentz's method
+	const tiny = 1e-300
+	f, c, d := 1.0, 1.0, 0.0
+	for i := 0; i <= 300; i++ {
+		m := float64(i / 2)
+		var num float64
+		switch {
+		case i == 0:
+			num = 1
+		case i%2 == 0:
+			num = m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		default:
+			num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		}
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		d = 1 / d
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		cd := c * d
+		f *= cd
+		if math.Abs(1-cd) < 1e-14 {
+			break
+		}
+	}
+	return front * (f - 1)
+}
@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestStudentQuantile(t *testing.T) {
+	tests := []struct {
+		p, df, expected float64
+	}{
+		{0.975, 1, 12.706},
+		{0.975, 9, 2.262},
+		{0.975, 30, 2.042},
+		{0.995, 9, 3.250},
+		{0.5, 5, 0},
+		{0.025, 9, -2.262},
+		{0.975, 1e6, 1.960},
+	}
+	for _, tt := range tests {
+		if got := StudentQuantile(tt.p, tt.df); math.Abs(got-tt.expected) > 5e-4 {
+			t.Errorf("StudentQuantile(%g, %g) = %.4f, expected %.3f", tt.p, tt.df, got, tt.expected)
+		}
+	}
+}
+
+func TestRanks(t *testing.T) {
+	tests := []struct {
+		xs, expected []float64
+	}{
+		{[]float64{30, 10, 20}, []float64{3, 1, 2}},
+		{[]float64{10, 20, 20, 30}, []float64{1, 2.5, 2.5, 4}},
+		{[]float64{5, 5, 5}, []float64{2, 2, 2}},
+		{[]float64{2, 1, 2, 1}, []float64{3.5, 1.5, 3.5, 1.5}},
+		{[]float64{}, []float64{}},
+	}
+	for _, tt := range tests {
+		if got := ranks(tt.xs); !slices.Equal(got, tt.expected) {
+			t.Errorf("ranks(%v) = %v, expected %v", tt.xs, got, tt.expected)
+		}
+	}
+}
+
+func TestSpearman_ties(t *testing.T) {
+	// monotonic with ties on both sides
+	x := []float64{1, 2, 2, 3, 4}
+	y := []float64{10, 20, 20, 40, 80}
+	if got := Spearman(x, y, nil); math.Abs(got-1) > 1e-12 {
+		t.Errorf("expected a monotonic relation to give 1, got %g", got)
+	}
+	if got := Spearman(x, []float64{1, 1, 1, 1, 1}, nil); !math.IsNaN(got) {
+		t.Errorf("expected NaN for a constant, got %g", got)
+	}
+}
+
+func TestOLS(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+	y := []float64{3, 5, 7, 9, 11}
+	f := OLS(x, y, nil, 0.95)
+	if math.Abs(float64(f.Slope.Value)-2) > 1e-12 || math.Abs(float64(f.Intercept)-1) > 1e-12 || math.Abs(float64(f.R2)-1) > 1e-12 {
+		t.Errorf("expected y = 1 + 2x with R² 1, got %+v", f)
+	}
+
+	// textbook case: slope 0.6, intercept 2.2, SE 0.2828 and the t of
+	// 3 degrees of freedom 3.182
+	f = OLS(x, []float64{2, 4, 5, 4, 5}, nil, 0.95)
+	if math.Abs(float64(f.Slope.Value)-0.6) > 1e-9 || math.Abs(float64(f.Intercept)-2.2) > 1e-9 {
+		t.Errorf("expected y = 2.2 + 0.6x, got %+v", f)
+	}
+	if math.Abs(float64(f.SlopeSE)-0.2828) > 1e-4 || math.Abs(float64(f.Slope.Low)-(0.6-3.182*0.2828)) > 1e-3 {
+		t.Errorf("expected the SE 0.2828 and the low bound %.4f, got %+v", 0.6-3.182*0.2828, f)
+	}
+
+	if f := OLS(x[:2], y[:2], nil, 0.95); !math.IsNaN(float64(f.Slope.Value)) {
+		t.Errorf("expected NaN with 2 points, got %+v", f)
+	}
+}
+
+// integer weights fit the same line as repeating the points, and scaling
+// the weights doesn't change the fit or its interval
+func TestOLS_weighted(t *testing.T) {
+	x := []float64{1, 2, 3, 4}
+	y := []float64{2, 3, 5, 4}
+	w := []float64{1, 2, 1, 3}
+	repeatedX := []float64{1, 2, 2, 3, 4, 4, 4}
+	repeatedY := []float64{2, 3, 3, 5, 4, 4, 4}
+
+	f := OLS(x, y, w, 0.95)
+	r := OLS(repeatedX, repeatedY, nil, 0.95)
+	if math.Abs(float64(f.Slope.Value-r.Slope.Value)) > 1e-12 || math.Abs(float64(f.Intercept-r.Intercept)) > 1e-12 {
+		t.Errorf("expected the weighted fit %+v to match the repeated points %+v", f, r)
+	}
+	if math.Abs(float64(f.R2-r.R2)) > 1e-12 {
+		t.Errorf("expected the R² %g of the repeated points, got %g", r.R2, f.R2)
+	}
+
+	scaled := OLS(x, y, []float64{10, 20, 10, 30}, 0.95)
+	if math.Abs(float64(scaled.Slope.Low-f.Slope.Low)) > 1e-12 || math.Abs(float64(scaled.Slope.High-f.Slope.High)) > 1e-12 {
+		t.Errorf("expected scaling the weights to keep the interval %v, got %v", f.Slope, scaled.Slope)
+	}
+	if got := Pearson(x, y, w); math.Abs(got-Pearson(repeatedX, repeatedY, nil)) > 1e-12 {
+		t.Errorf("expected the weighted correlation of the repeated points, got %g", got)
+	}
+}
+
+func TestPermutation(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	y := []float64{2, 1, 4, 3, 6, 5, 8, 7}
+
+	p := Permutation(x, y, nil, Pearson, 999, 1)
+	if p <= 0 || p > 0.01 {
+		t.Errorf("expected a strong correlation of 8 points to be significant, got p = %g", p)
+	}
+	if again := Permutation(x, y, nil, Pearson, 999, 1); again != p {
+		t.Errorf("expected the same p-value from the same seed, got %g and %g", p, again)
+	}
+	// the observed order counts as a shuffle, so p is never 0
+	if got := Permutation(x, x, nil, Spearman, 9, 1); got < 0.1 {
+		t.Errorf("expected p at least 1/10 with 9 shuffles, got %g", got)
+	}
+
+	flat := []float64{3, 1, 4, 1, 5, 9, 2, 6}
+	if got := Permutation(x, flat, nil, Pearson, 999, 1); got < 0.05 {
+		t.Errorf("expected no significant correlation with shuffled data, got p = %g", got)
+	}
+	if got := Permutation(x, []float64{1, 1, 1, 1, 1, 1, 1, 1}, nil, Pearson, 99, 1); !math.IsNaN(got) {
+		t.Errorf("expected NaN for a constant, got %g", got)
+	}
+}
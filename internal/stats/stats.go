@@ -0,0 +1,121 @@
+// Package stats has the estimators behind the ratios of the communities.
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Float is written into JSON as null when it is NaN or infinite, such as
+// the M:F ratio of a community without a female member
+type Float float64
+
+func (f Float) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, float64(f), 'g', -1, 64), nil
+}
+
+func (f *Float) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*f = Float(math.NaN())
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*f = Float(v)
+	return nil
+}
+
+// Interval is an estimate with the bounds of its confidence interval
+type Interval struct {
+	Value Float `json:"value"`
+	Low   Float `json:"low"`
+	High  Float `json:"high"`
+}
+
+func nan() Interval {
+	return Interval{Float(math.NaN()), Float(math.NaN()), Float(math.NaN())}
+}
+
+// z is the quantile of the standard normal distribution for the two sided
+// confidence level, eg. 1.96 for 0.95
+func z(level float64) float64 {
+	return math.Sqrt2 * math.Erfinv(level)
+}
+
+// Wilson is the share of k in n with the Wilson score interval, which
+// stays within [0, 1] and doesn't collapse when k is 0 or n. All of them
+// are NaN when n is 0.
+func Wilson(k, n int, level float64) Interval {
+	if n == 0 {
+		return nan()
+	}
+	var (
+		p     = float64(k) / float64(n)
+		z     = z(level)
+		z2    = z * z
+		nf    = float64(n)
+		mid   = (p + z2/(2*nf)) / (1 + z2/nf)
+		width = z / (1 + z2/nf) * math.Sqrt(p*(1-p)/nf+z2/(4*nf*nf))
+	)
+	return Interval{Float(p), Float(max(0, mid-width)), Float(min(1, mid+width))}
+}
+
+// MalePerFemale converts the interval of the female share into the M:F
+// ratio, the males per a female. A share of 0 gives an infinite ratio.
+func MalePerFemale(share Interval) Interval {
+	r := func(p Float) Float {
+		if p == 0 {
+			return Float(math.Inf(1))
+		}
+		return (1 - p) / p
+	}
+	// the ratio decreases with the share, so the bounds swap
+	return Interval{r(share.Value), r(share.High), r(share.Low)}
+}
+
+// Percentiles is the interval between the lower and upper percentiles of
+// the samples for the two sided confidence level, around their median.
+// NaN samples are dropped; all of them are NaN without a sample left.
+func Percentiles(samples []float64, level float64) Interval {
+	xs := []float64{}
+	for _, x := range samples {
+		if !math.IsNaN(x) {
+			xs = append(xs, x)
+		}
+	}
+	if len(xs) == 0 {
+		return nan()
+	}
+	slices.Sort(xs)
+	q := func(p float64) Float {
+		// linear interpolation between the closest ranks
+		h := p * float64(len(xs)-1)
+		lo := int(math.Floor(h))
+		hi := min(lo+1, len(xs)-1)
+		// on a rank or between equal samples, which avoids 0×Inf and
+		// Inf-Inf with the infinite ratios
+		if h == float64(lo) || xs[lo] == xs[hi] {
+			return Float(xs[lo])
+		}
+		return Float(xs[lo] + (h-float64(lo))*(xs[hi]-xs[lo]))
+	}
+	return Interval{q(0.5), q((1 - level) / 2), q((1 + level) / 2)}
+}
+
+// Normal is the share of an expected count in n with the interval of the
+// normal approximation from the variance of the count, clipped to [0, 1]
+func Normal(expected, variance float64, n int, level float64) Interval {
+	if n == 0 {
+		return nan()
+	}
+	p := expected / float64(n)
+	width := z(level) * math.Sqrt(variance) / float64(n)
+	return Interval{Float(p), Float(max(0, p-width)), Float(min(1, p+width))}
+}
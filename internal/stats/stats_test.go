@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// near compares within the rounding of the values given in the tests, and
+// takes NaN as equal to NaN
+func near(got Float, expected, tolerance float64) bool {
+	if math.IsNaN(expected) || math.IsInf(expected, 0) {
+		return math.IsNaN(float64(got)) == math.IsNaN(expected) && math.IsInf(float64(got), 1) == math.IsInf(expected, 1)
+	}
+	return math.Abs(float64(got)-expected) <= tolerance
+}
+
+func nearInterval(got Interval, value, low, high, tolerance float64) bool {
+	return near(got.Value, value, tolerance) && near(got.Low, low, tolerance) && near(got.High, high, tolerance)
+}
+
+func TestWilson(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		k, n             int
+		level            float64
+		value, low, high float64
+	}{
+		{10, 100, 0.95, 0.1, 0.0552, 0.1744},
+		{50, 100, 0.95, 0.5, 0.4038, 0.5962},
+		{0, 10, 0.95, 0, 0, 0.2775},
+		{10, 10, 0.95, 1, 0.7225, 1},
+		{0, 0, 0.95, nan, nan, nan},
+		{10, 100, 0.99, 0.1, 0.0460, 0.2038},
+	}
+	for _, tt := range tests {
+		if got := Wilson(tt.k, tt.n, tt.level); !nearInterval(got, tt.value, tt.low, tt.high, 1e-4) {
+			t.Errorf("Wilson(%d, %d, %g) = %v, expected %g (%g – %g)", tt.k, tt.n, tt.level, got, tt.value, tt.low, tt.high)
+		}
+	}
+}
+
+func TestMalePerFemale(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	none := Interval{Float(nan), Float(nan), Float(nan)}
+	tests := []struct {
+		share            Interval
+		value, low, high float64
+	}{
+		// the bounds swap, the high share gives the low ratio
+		{Interval{0.2, 0.1, 0.25}, 4, 3, 9},
+		{Interval{0.5, 0.5, 0.5}, 1, 1, 1},
+		{Interval{0, 0, 0.2775}, inf, 2.6036, inf},
+		{Interval{1, 0.7225, 1}, 0, 0, 0.3841},
+		{none, nan, nan, nan},
+	}
+	for _, tt := range tests {
+		if got := MalePerFemale(tt.share); !nearInterval(got, tt.value, tt.low, tt.high, 1e-4) {
+			t.Errorf("MalePerFemale(%v) = %v, expected %g (%g – %g)", tt.share, got, tt.value, tt.low, tt.high)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	hundred := []float64{}
+	for i := range 101 {
+		hundred = append(hundred, float64(100-i))
+	}
+	tests := []struct {
+		name             string
+		samples          []float64
+		level            float64
+		value, low, high float64
+	}{
+		{"unsorted", hundred, 0.9, 50, 5, 95},
+		{"interpolated", []float64{0, 10}, 0.5, 5, 2.5, 7.5},
+		{"nan dropped", []float64{nan, 1, 2, 3, nan}, 0.5, 2, 1.5, 2.5},
+		{"all nan", []float64{nan, nan}, 0.95, nan, nan, nan},
+		{"empty", nil, 0.95, nan, nan, nan},
+		{"infinite", []float64{1, 1, inf}, 0.5, 1, 1, inf},
+	}
+	for _, tt := range tests {
+		if got := Percentiles(tt.samples, tt.level); !nearInterval(got, tt.value, tt.low, tt.high, 1e-9) {
+			t.Errorf("%s: Percentiles = %v, expected %g (%g – %g)", tt.name, got, tt.value, tt.low, tt.high)
+		}
+	}
+}
+
+func TestNormal(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		expected, variance float64
+		n                  int
+		value, low, high   float64
+	}{
+		{30, 21, 100, 0.3, 0.2102, 0.3898},
+		{1, 4, 10, 0.1, 0, 0.4920},
+		{9, 4, 10, 0.9, 0.5080, 1},
+		{5, 0, 10, 0.5, 0.5, 0.5},
+		{0, 0, 0, nan, nan, nan},
+	}
+	for _, tt := range tests {
+		if got := Normal(tt.expected, tt.variance, tt.n, 0.95); !nearInterval(got, tt.value, tt.low, tt.high, 1e-4) {
+			t.Errorf("Normal(%g, %g, %d) = %v, expected %g (%g – %g)", tt.expected, tt.variance, tt.n, got, tt.value, tt.low, tt.high)
+		}
+	}
+}
+
+func TestFloat_JSON(t *testing.T) {
+	b, err := json.Marshal([]Float{0.5, Float(math.NaN()), Float(math.Inf(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "[0.5,null,null]" {
+		t.Errorf("expected NaN and Inf as null, got %s", b)
+	}
+	fs := []Float{}
+	if err := json.Unmarshal(b, &fs); err != nil {
+		t.Fatal(err)
+	}
+	if fs[0] != 0.5 || !math.IsNaN(float64(fs[1])) {
+		t.Errorf("expected null read back as NaN, got %v", fs)
+	}
+}
@@ -0,0 +1,224 @@
+// Tests the relationship between the maturity of the languages and the
+// masculinity of their communities, as in the Comparison of the README.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"main/internal/ratio"
+	"main/internal/stats"
+)
+
+type Args struct {
+	Maturity     string
+	Stats        string
+	Weighted     bool
+	Level        float64
+	Permutations int
+	Seed         uint64
+	JSON         bool
+}
+
+// Point is a subject with the M:F ratio of its communities
+type Point struct {
+	Subject     string   `json:"subject"`
+	Maturity    float64  `json:"maturity"`    // years
+	Masculinity float64  `json:"masculinity"` // males per a female
+	Members     int      `json:"members,omitempty"`
+	Communities []string `json:"communities,omitempty"`
+}
+
+// Correlation is a coefficient with the p-value of its permutation test
+type Correlation struct {
+	R stats.Float `json:"r"`
+	P stats.Float `json:"p"`
+}
+
+type Analysis struct {
+	Points       []Point     `json:"points"`
+	Weighted     bool        `json:"weighted"`
+	Level        float64     `json:"level"`
+	Permutations int         `json:"permutations"`
+	Seed         uint64      `json:"seed"`
+	Pearson      Correlation `json:"pearson"`
+	Spearman     Correlation `json:"spearman"`
+	OLS          stats.Fit   `json:"ols"` // masculinity by maturity
+}
+
+// readMaturity reads the CSV with the columns of subject, maturity,
+// masculinity and the communities separated by ";"
+func readMaturity(path string) ([]Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if len(rows) == 0 || !slices.Equal(rows[0], []string{"subject", "maturity", "masculinity", "communities"}) {
+		return nil, fmt.Errorf("expected the header subject,maturity,masculinity,communities")
+	}
+	points := []Point{}
+	for i, row := range rows[1:] {
+		p := Point{Subject: row[0]}
+		if p.Maturity, err = strconv.ParseFloat(row[1], 64); err != nil {
+			return nil, fmt.Errorf("line %d: maturity: %w", i+2, err)
+		}
+		if p.Masculinity, err = strconv.ParseFloat(row[2], 64); err != nil {
+			return nil, fmt.Errorf("line %d: masculinity: %w", i+2, err)
+		}
+		if row[3] != "" {
+			p.Communities = strings.Split(row[3], ";")
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// pool replaces the masculinity of the points by the ratio of the male
+// and female members of their communities in the output of the stats
+// command, and sets their member counts
+func pool(points []Point, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	results := []ratio.Result{}
+	if err := json.Unmarshal(b, &results); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+	byCommunity := map[string]ratio.Result{}
+	for _, r := range results {
+		byCommunity[r.Community] = r
+	}
+
+	for i, p := range points {
+		male, female := 0, 0
+		for _, c := range p.Communities {
+			r, ok := byCommunity[c]
+			if !ok {
+				return fmt.Errorf("%s: missing community: %s", p.Subject, c)
+			}
+			male += r.Male
+			female += r.Female
+		}
+		if female == 0 {
+			return fmt.Errorf("%s: no female member to take the ratio", p.Subject)
+		}
+		points[i].Masculinity = float64(male) / float64(female)
+		points[i].Members = male + female
+	}
+	return nil
+}
+
+func analyze(points []Point, args Args) Analysis {
+	x, y := []float64{}, []float64{}
+	var w []float64
+	if args.Weighted {
+		w = []float64{}
+	}
+	for _, p := range points {
+		x = append(x, p.Maturity)
+		y = append(y, p.Masculinity)
+		if args.Weighted {
+			w = append(w, float64(p.Members))
+		}
+	}
+	return Analysis{
+		Points:       points,
+		Weighted:     args.Weighted,
+		Level:        args.Level,
+		Permutations: args.Permutations,
+		Seed:         args.Seed,
+		Pearson: Correlation{
+			R: stats.Float(stats.Pearson(x, y, w)),
+			P: stats.Float(stats.Permutation(x, y, w, stats.Pearson, args.Permutations, args.Seed)),
+		},
+		Spearman: Correlation{
+			R: stats.Float(stats.Spearman(x, y, w)),
+			P: stats.Float(stats.Permutation(x, y, w, stats.Spearman, args.Permutations, args.Seed)),
+		},
+		OLS: stats.OLS(x, y, w, args.Level),
+	}
+}
+
+func printAnalysis(a Analysis) {
+	fmt.Printf("%-12s %8s %11s %8s\n", "subject", "maturity", "masculinity", "members")
+	for _, p := range a.Points {
+		members := "-"
+		if p.Members > 0 {
+			members = strconv.Itoa(p.Members)
+		}
+		fmt.Printf("%-12s %8g %11.2f %8s\n", p.Subject, p.Maturity, p.Masculinity, members)
+	}
+	fmt.Println()
+	weighting := "unweighted"
+	if a.Weighted {
+		weighting = "weighted by members"
+	}
+	fmt.Printf("%d subjects, %s, %d permutations with seed %d\n", len(a.Points), weighting, a.Permutations, a.Seed)
+	fmt.Printf("pearson  r: %6.3f (p = %.4f)\n", a.Pearson.R, a.Pearson.P)
+	fmt.Printf("spearman ρ: %6.3f (p = %.4f)\n", a.Spearman.R, a.Spearman.P)
+	fmt.Printf("ols       : masculinity = %.3f + %.4f × maturity, R² = %.3f\n", a.OLS.Intercept, a.OLS.Slope.Value, a.OLS.R2)
+	fmt.Printf("slope     : %.4f (%g%% CI %.4f – %.4f, SE %.4f)\n", a.OLS.Slope.Value, 100*a.Level, a.OLS.Slope.Low, a.OLS.Slope.High, a.OLS.SlopeSE)
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.Maturity, "maturity", "maturity.csv", "subjects with their maturity in years, masculinity and communities")
+	flag.StringVar(&args.Stats, "stats", "", "stats.json of the stats command, for the masculinity and the members of the communities instead of the maturity file")
+	flag.BoolVar(&args.Weighted, "weighted", false, "weight the subjects by their members counted, needs --stats")
+	flag.Float64Var(&args.Level, "level", 0.95, "confidence level of the slope interval")
+	flag.IntVar(&args.Permutations, "permutations", 10000, "shuffles for the p-values of the correlations")
+	flag.Uint64Var(&args.Seed, "seed", 1, "seed of the shuffles")
+	flag.BoolVar(&args.JSON, "json", false, "print the results as JSON")
+	flag.Parse()
+
+	if args.Level <= 0 || args.Level >= 1 {
+		return fmt.Errorf("expected the level between 0 and 1: %g", args.Level)
+	}
+	if args.Weighted && args.Stats == "" {
+		return fmt.Errorf("weighting by members needs the counts of --stats")
+	}
+
+	points, err := readMaturity(args.Maturity)
+	if err != nil {
+		return fmt.Errorf("reading maturity: %w", err)
+	}
+	if args.Stats != "" {
+		if err := pool(points, args.Stats); err != nil {
+			return fmt.Errorf("pooling stats: %w", err)
+		}
+	}
+	if len(points) < 3 {
+		return fmt.Errorf("expected at least 3 subjects, got %d", len(points))
+	}
+
+	a := analyze(points, args)
+	if args.JSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(a); err != nil {
+			return fmt.Errorf("encoding: %w", err)
+		}
+		return nil
+	}
+	printAnalysis(a)
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"main/internal/normalize"
+)
+
+type cacheEntry struct {
+	Model  string      `json:"model"`
+	Key    string      `json:"key"`
+	Answer LabeledName `json:"answer"`
+}
+
+// Cache keeps the answers of earlier runs, so only the names never seen
+// by a model are sent to it. There is a file for each version of the
+// prompt, as a changed prompt invalidates every answer.
+type Cache struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]LabeledName // by model and key
+}
+
+func cacheKey(name string) string {
+	return normalize.Name(name)
+}
+
+func promptHash(prompt string) string {
+	h := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(h[:8])
+}
+
+func OpenCache(dir, prompt string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("mkdir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, promptHash(prompt)+".jsonl"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	c := &Cache{f: f, entries: map[string]LabeledName{}}
+	err = readLines(f, func(line []byte) {
+		e := cacheEntry{}
+		if err := json.Unmarshal(line, &e); err != nil {
+			return
+		}
+		c.entries[e.Model+"\x00"+e.Key] = e.Answer
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Split returns the cached answers, renamed to the asked spelling, and
+// the names that are missing in the cache
+func (c *Cache) Split(model string, names []string) ([]LabeledName, []string) {
+	if c == nil {
+		return nil, names
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, rest := []LabeledName{}, []string{}
+	for _, name := range names {
+		if a, ok := c.entries[model+"\x00"+cacheKey(name)]; ok {
+			a.Name = name
+			cached = append(cached, a)
+		} else {
+			rest = append(rest, name)
+		}
+	}
+	return cached, rest
+}
+
+func (c *Cache) Put(model string, items []LabeledName) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := []byte{}
+	for _, item := range items {
+		e := cacheEntry{Model: model, Key: cacheKey(item.Name), Answer: item}
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		b = append(append(b, line...), '\n')
+		c.entries[e.Model+"\x00"+e.Key] = item
+	}
+	if _, err := c.f.Write(b); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	if err := c.f.Sync(); err != nil {
+		c.f.Close()
+		return fmt.Errorf("sync: %w", err)
+	}
+	return c.f.Close()
+}
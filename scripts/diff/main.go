@@ -0,0 +1,132 @@
+// Lists the names labeled differently by two runs, and how the M:F ratio
+// of each community would move if the new run were adopted.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"main/internal/labels"
+	"main/internal/members"
+	"main/internal/ratio"
+	"main/internal/stats"
+)
+
+type Args struct {
+	Frequencies string
+}
+
+// Change is a name labeled differently by the runs. Old or New is empty
+// for the names present in only one of the runs.
+type Change struct {
+	Name, Old, New string
+}
+
+func included(gender string) bool {
+	return gender == "male" || gender == "female"
+}
+
+// formatRatio is the M:F ratio printed as the ratio command does
+func formatRatio(t labels.Tally) string {
+	return ratio.FormatRatio(stats.Float(float64(t.Male) / float64(t.Female)))
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.Frequencies, "frequencies", "labels/name-frequencies.tsv", "member counts of the names written by ingest, for the ratios of the communities (empty to skip)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: diff [flags] <old run dir> <new run dir>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		return fmt.Errorf("expected two run directories")
+	}
+	oldDir, newDir := flag.Arg(0), flag.Arg(1)
+	before, err := labels.Read(oldDir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", oldDir, err)
+	}
+	after, err := labels.Read(newDir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", newDir, err)
+	}
+
+	flipped, moved, onlyOld, onlyNew := []Change{}, []Change{}, []Change{}, []Change{}
+	for _, name := range before.Names() {
+		o, n := before[name], after[name]
+		switch {
+		case n == "":
+			onlyOld = append(onlyOld, Change{name, o, n})
+		case o == n:
+		case included(o) && included(n):
+			flipped = append(flipped, Change{name, o, n})
+		default:
+			moved = append(moved, Change{name, o, n})
+		}
+	}
+	for _, name := range after.Names() {
+		if _, ok := before[name]; !ok {
+			onlyNew = append(onlyNew, Change{name, "", after[name]})
+		}
+	}
+
+	section := func(title string, cs []Change) {
+		fmt.Printf("%s: %d\n", title, len(cs))
+		for _, c := range cs {
+			switch {
+			case c.Old == "":
+				fmt.Printf("  %s: %s\n", c.Name, c.New)
+			case c.New == "":
+				fmt.Printf("  %s: %s\n", c.Name, c.Old)
+			default:
+				fmt.Printf("  %s: %s -> %s\n", c.Name, c.Old, c.New)
+			}
+		}
+	}
+	section("flipped male<->female", flipped)
+	section("moved to or from unisex/unknown", moved)
+	section("only in "+oldDir, onlyOld)
+	section("only in "+newDir, onlyNew)
+
+	if args.Frequencies == "" {
+		return nil
+	}
+	f, err := members.ReadFrequencies(args.Frequencies)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("WARNING: skipping the ratios of the communities: %v\n", err)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading frequencies: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-24s %18s %18s %10s %10s\n", "community", "old M/F/excl.", "new M/F/excl.", "old M:F", "new M:F")
+	for _, c := range f.Communities {
+		ot, nt := labels.Tally{}, labels.Tally{}
+		for name, counts := range f.Counts {
+			if counts[c] == 0 {
+				continue
+			}
+			og, _ := before.Lookup(name)
+			ot.Add(og, counts[c])
+			ng, _ := after.Lookup(name)
+			nt.Add(ng, counts[c])
+		}
+		fmt.Printf("%-24s %18s %18s %10s %10s\n", c,
+			fmt.Sprintf("%d/%d/%d", ot.Male, ot.Female, ot.Excluded),
+			fmt.Sprintf("%d/%d/%d", nt.Male, nt.Female, nt.Excluded),
+			formatRatio(ot), formatRatio(nt))
+	}
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
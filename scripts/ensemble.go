@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type EnsembleMember struct {
+	Model  string
+	Weight float64
+}
+
+// Ensemble asks the same question to each member and takes a weighted
+// vote per name. A run with a single model is an ensemble of one.
+type Ensemble struct {
+	Members []EnsembleMember
+	// the share of the total weight the winning label needs, otherwise
+	// the name is labeled unknown
+	Quorum float64
+	Cache  *Cache // nil to ask every name
+}
+
+// ParseEnsemble reads a comma separated list of models with optional
+// weights, eg. "googleai/gemini-2.5-flash=2,googleai/gemini-2.5-pro"
+func ParseEnsemble(s string) ([]EnsembleMember, error) {
+	members := []EnsembleMember{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		m := EnsembleMember{Model: field, Weight: 1}
+		if i := strings.LastIndex(field, "="); i != -1 {
+			w, err := strconv.ParseFloat(field[i+1:], 64)
+			if err != nil {
+				return nil, fmt.Errorf("weight of %q: %w", field[:i], err)
+			}
+			if w <= 0 {
+				return nil, fmt.Errorf("weight of %q: should be positive", field[:i])
+			}
+			m.Model, m.Weight = field[:i], w
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// Disagreement is written for each name the members didn't answer the same
+type Disagreement struct {
+	Name    string                 `json:"name"`
+	Answers map[string]LabeledName `json:"answers"` // by model, missing if the model failed or skipped it
+	Gender  string                 `json:"gender"`  // the voted label
+	Share   float64                `json:"share"`   // of the total weight that voted for the winner
+}
+
+func (e Ensemble) totalWeight() float64 {
+	total := 0.0
+	for _, m := range e.Members {
+		total += m.Weight
+	}
+	return total
+}
+
+// vote expects answers by the index of the member
+func (e Ensemble) vote(name string, answers map[int]LabeledName) (LabeledName, *Disagreement) {
+	weights := map[string]float64{}
+	winner := ""
+	for i, m := range e.Members {
+		a, ok := answers[i]
+		if !ok {
+			continue
+		}
+		weights[a.Gender] += m.Weight
+		if winner == "" || weights[a.Gender] > weights[winner] {
+			winner = a.Gender
+		}
+	}
+
+	total := e.totalWeight()
+	share := weights[winner] / total
+
+	voted := LabeledName{Name: name, Gender: winner}
+	for i, m := range e.Members {
+		if a, ok := answers[i]; ok && a.Gender == winner {
+			voted.Confidence += m.Weight * a.Confidence / total
+			if voted.Rationale == "" {
+				voted.Rationale = a.Rationale
+			}
+		}
+	}
+	if share < e.Quorum {
+		voted.Gender = "unknown"
+		voted.Rationale = fmt.Sprintf("no quorum, %q got %.2f of the votes", winner, share)
+	}
+
+	if len(weights) == 1 && len(answers) == len(e.Members) {
+		return voted, nil
+	}
+	d := &Disagreement{
+		Name:    name,
+		Answers: map[string]LabeledName{},
+		Gender:  voted.Gender,
+		Share:   share,
+	}
+	for i, a := range answers {
+		d.Answers[e.Members[i].Model] = a
+	}
+	return voted, d
+}
+
+// pFemale is the probability of the name being female by an answer. The
+// confidence of a male answer is the probability of the name being male,
+// unisex is even and unknown tells nothing.
+func pFemale(a LabeledName) (float64, bool) {
+	c := min(1, max(0, a.Confidence))
+	switch a.Gender {
+	case "female":
+		return c, true
+	case "male":
+		return 1 - c, true
+	case "unisex":
+		return 0.5, true
+	}
+	return 0, false
+}
+
+// soft is the probability of the name being female averaged over the
+// answers of the members by their weights
+func (e Ensemble) soft(answers map[int]LabeledName) (float64, bool) {
+	sum, weights := 0.0, 0.0
+	for i, m := range e.Members {
+		if a, ok := answers[i]; ok {
+			if p, ok := pFemale(a); ok {
+				sum += m.Weight * p
+				weights += m.Weight
+			}
+		}
+	}
+	if weights == 0 {
+		return 0, false
+	}
+	return sum / weights, true
+}
+
+// Labeled is the outcome of a batch
+type Labeled struct {
+	Answer        *Answer
+	Failed        []FailedName
+	Stats         ReconcileStats
+	Disagreements []Disagreement
+	Cached        int                // answers taken from the cache, summed over the members
+	Overridden    int                // answers from the overrides, never asked
+	PFemale       map[string]float64 // the soft labels, by name
+}
+
+// FromCache labels the names every member has a cached answer for, as
+// [Ensemble.Label] would, and returns them to be left out of the batches
+func (e Ensemble) FromCache(names []string) (*Labeled, []string) {
+	l := &Labeled{Answer: &Answer{}, PFemale: map[string]float64{}}
+	answers := map[string]map[int]LabeledName{}
+	for i, m := range e.Members {
+		cached, _ := e.Cache.Split(m.Model, names)
+		for _, item := range cached {
+			if answers[item.Name] == nil {
+				answers[item.Name] = map[int]LabeledName{}
+			}
+			answers[item.Name][i] = item
+		}
+	}
+
+	hits := []string{}
+	for _, name := range names {
+		if len(answers[name]) < len(e.Members) {
+			continue
+		}
+		hits = append(hits, name)
+		l.Cached += len(e.Members)
+		item, d := e.vote(name, answers[name])
+		l.Answer.Items = append(l.Answer.Items, item)
+		if p, ok := e.soft(answers[name]); ok {
+			l.PFemale[name] = p
+		}
+		if d != nil {
+			l.Disagreements = append(l.Disagreements, *d)
+		}
+	}
+	return l, hits
+}
+
+// Label labels the names with each member and votes. A name is only
+// quarantined when all members failed on it. Names cached for a member
+// are not sent to it.
+func (e Ensemble) Label(ctx context.Context, run func(context.Context, *Question) (*Answer, error), p RetryPolicy, names []string) (*Labeled, error) {
+	l := &Labeled{Answer: &Answer{}, PFemale: map[string]float64{}}
+	answers := map[string]map[int]LabeledName{}
+	failures := map[string]error{}
+
+	for i, m := range e.Members {
+		runWith := func(ctx context.Context, q *Question) (*Answer, error) {
+			q2 := *q
+			q2.Model = m.Model
+			return run(ctx, &q2)
+		}
+		cached, rest := e.Cache.Split(m.Model, names)
+		l.Cached += len(cached)
+
+		a, f, s, err := labelReconciled(ctx, runWith, p, rest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Model, err)
+		}
+		if err := e.Cache.Put(m.Model, a.Items); err != nil {
+			return nil, fmt.Errorf("caching answers: %w", err)
+		}
+		l.Stats.Add(s)
+		for _, item := range append(cached, a.Items...) {
+			if answers[item.Name] == nil {
+				answers[item.Name] = map[int]LabeledName{}
+			}
+			answers[item.Name][i] = item
+		}
+		for _, fn := range f {
+			if _, ok := failures[fn.Name]; !ok {
+				failures[fn.Name] = fn.Err
+			}
+		}
+	}
+
+	for _, name := range names {
+		if len(answers[name]) == 0 {
+			l.Failed = append(l.Failed, FailedName{Name: name, Err: failures[name]})
+			continue
+		}
+		item, d := e.vote(name, answers[name])
+		l.Answer.Items = append(l.Answer.Items, item)
+		if p, ok := e.soft(answers[name]); ok {
+			l.PFemale[name] = p
+		}
+		if d != nil {
+			l.Disagreements = append(l.Disagreements, *d)
+		}
+	}
+	return l, nil
+}
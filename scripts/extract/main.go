@@ -0,0 +1,245 @@
+// Extracts the member names from saved HTML snapshots of a Kommunity
+// members page into the member list of the community under data/, in
+// place of running the snippet in the README on the browser console.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type Args struct {
+	URL, Community, Captured, Data string
+}
+
+// Snapshot is a saved members page
+type Snapshot struct {
+	Path     string
+	URL      string
+	Captured time.Time
+	Names    []string // full names in the page order
+	Warning  string   // when the markup might be changed
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, a := range n.Attr {
+		if a.Key == "class" && slices.Contains(strings.Fields(a.Val), class) {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func text(n *html.Node) string {
+	b := strings.Builder{}
+	for d := range n.Descendants() {
+		if d.Type == html.TextNode {
+			b.WriteString(d.Data)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// browsers mark the saved pages with the address they are saved from
+var savedFrom = regexp.MustCompile(`saved from url=\(\d+\)(\S+)`)
+
+// sourceURL looks for the address of the page in the "saved from" comment,
+// the canonical link and the og:url meta, in this order
+func sourceURL(doc *html.Node) string {
+	canonical, og := "", ""
+	for n := range doc.Descendants() {
+		switch {
+		case n.Type == html.CommentNode:
+			if m := savedFrom.FindStringSubmatch(n.Data); m != nil {
+				return m[1]
+			}
+		case n.Type == html.ElementNode && n.Data == "link" && attr(n, "rel") == "canonical":
+			canonical = attr(n, "href")
+		case n.Type == html.ElementNode && n.Data == "meta" && attr(n, "property") == "og:url":
+			og = attr(n, "content")
+		}
+	}
+	if canonical != "" {
+		return canonical
+	}
+	return og
+}
+
+// names mirrors the selector of the snippet in the README: the .full-name
+// elements under div.user-list. The counts of the matches are returned
+// to tell an empty page apart from a changed markup.
+func names(doc *html.Node) (lists int, names []string) {
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode || n.Data != "div" || !hasClass(n, "user-list") {
+			continue
+		}
+		lists++
+		for d := range n.Descendants() {
+			if d.Type == html.ElementNode && hasClass(d, "full-name") {
+				if t := text(d); t != "" {
+					names = append(names, t)
+				}
+			}
+		}
+	}
+	return lists, names
+}
+
+func ReadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+
+	doc, err := html.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	s := &Snapshot{
+		Path:     path,
+		URL:      sourceURL(doc),
+		Captured: fi.ModTime().UTC(),
+	}
+	lists, ns := names(doc)
+	switch {
+	case lists == 0:
+		s.Warning = "no div.user-list in the page, the markup might be changed"
+	case len(ns) == 0:
+		s.Warning = "no .full-name in div.user-list, the markup might be changed"
+	}
+	s.Names = ns
+	return s, nil
+}
+
+// community is the first segment of the path, eg. "goturkiye" for
+// https://kommunity.com/goturkiye/members
+func community(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	first, _, _ := strings.Cut(strings.Trim(p.Path, "/"), "/")
+	return first
+}
+
+// givenPart drops the last word of the full name as the surname, leaving
+// the given names for the ingest command to pick the compounds from. A
+// member without a surname loses the second given name, as "Mehmet Ali"
+// is left as "Mehmet".
+func givenPart(fullname string) string {
+	words := strings.Fields(fullname)
+	if len(words) > 1 {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.URL, "url", "", "address of the page, when the snapshot doesn't have it")
+	flag.StringVar(&args.Community, "community", "", "name of the member list, by default the first segment of the url")
+	flag.StringVar(&args.Captured, "captured", "", "capture time of the snapshots in RFC 3339, by default the modification time of the files")
+	flag.StringVar(&args.Data, "data", "data", "directory of the member lists")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		return fmt.Errorf("usage: extract [flags] snapshot.html...")
+	}
+
+	snapshots := []*Snapshot{}
+	for _, path := range flag.Args() {
+		s, err := ReadSnapshot(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		if s.Warning != "" {
+			fmt.Fprintf(os.Stderr, "WARNING: %s: %s\n", path, s.Warning)
+		}
+		if args.URL != "" {
+			s.URL = args.URL
+		}
+		if args.Captured != "" {
+			s.Captured, err = time.Parse(time.RFC3339, args.Captured)
+			if err != nil {
+				return fmt.Errorf("parsing capture time: %w", err)
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	name := args.Community
+	if name == "" {
+		name = community(snapshots[0].URL)
+	}
+	if name == "" {
+		return fmt.Errorf("no address in %s to name the community, use --community or --url", snapshots[0].Path)
+	}
+	for _, s := range snapshots[1:] {
+		if c := community(s.URL); args.Community == "" && c != name {
+			return fmt.Errorf("%s is a snapshot of %q, not %q", s.Path, c, name)
+		}
+	}
+
+	total := 0
+	for _, s := range snapshots {
+		total += len(s.Names)
+	}
+	path := filepath.Join(args.Data, name+".txt")
+	// an earlier list shouldn't be emptied because of a changed markup
+	if total == 0 {
+		return fmt.Errorf("no names in the snapshots, %s is left as is", path)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "# source: %s\n", s.URL)
+		fmt.Fprintf(w, "# captured: %s\n", s.Captured.Format(time.RFC3339))
+		for _, n := range s.Names {
+			fmt.Fprintln(w, givenPart(n))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	fmt.Printf("%s: %d members\n", path, total)
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestReadSnapshot(t *testing.T) {
+	s, err := ReadSnapshot("testdata/members.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the full name of the signed in user is out of div.user-list, and
+	// the empty one is skipped
+	expected := []string{"Ahmet Yılmaz", "Ayşe Nur Kaya", "Cher"}
+	if !slices.Equal(s.Names, expected) {
+		t.Errorf("expected %v, got %v", expected, s.Names)
+	}
+	if s.URL != "https://kommunity.com/goturkiye/members?page=2" {
+		t.Errorf("expected the address of the saved from comment, got %q", s.URL)
+	}
+	if s.Warning != "" {
+		t.Errorf("expected no warning, got %q", s.Warning)
+	}
+}
+
+func TestReadSnapshot_markupChanged(t *testing.T) {
+	tests := []struct {
+		path, warning string
+	}{
+		{"testdata/changed.html", "no div.user-list"},
+		{"testdata/renamed.html", "no .full-name"},
+	}
+	for _, tt := range tests {
+		s, err := ReadSnapshot(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Names) != 0 || !strings.HasPrefix(s.Warning, tt.warning) {
+			t.Errorf("%s: expected the warning %q without names, got %q and %v", tt.path, tt.warning, s.Warning, s.Names)
+		}
+	}
+}
+
+func TestSourceURL(t *testing.T) {
+	tests := []struct {
+		name, page, expected string
+	}{
+		{"saved from first", `<!-- saved from url=(0031)https://kommunity.com/a/members --><html><head><link rel="canonical" href="https://kommunity.com/b"><meta property="og:url" content="https://kommunity.com/c"></head></html>`, "https://kommunity.com/a/members"},
+		{"canonical over og", `<html><head><meta property="og:url" content="https://kommunity.com/c"><link rel="canonical" href="https://kommunity.com/b"></head></html>`, "https://kommunity.com/b"},
+		{"og", `<html><head><meta property="og:url" content="https://kommunity.com/c"></head></html>`, "https://kommunity.com/c"},
+		{"none", `<html><head><link rel="stylesheet" href="x.css"></head></html>`, ""},
+	}
+	for _, tt := range tests {
+		doc, err := html.Parse(strings.NewReader(tt.page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sourceURL(doc); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestCommunity(t *testing.T) {
+	tests := []struct {
+		url, expected string
+	}{
+		{"https://kommunity.com/goturkiye/members", "goturkiye"},
+		{"https://kommunity.com/goturkiye/members?page=2", "goturkiye"},
+		{"https://kommunity.com/goturkiye", "goturkiye"},
+		{"https://kommunity.com/", ""},
+		{"", ""},
+		{"://bad", ""},
+	}
+	for _, tt := range tests {
+		if got := community(tt.url); got != tt.expected {
+			t.Errorf("community(%q) = %q, expected %q", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestGivenPart(t *testing.T) {
+	tests := []struct {
+		fullname, expected string
+	}{
+		{"Ahmet Yılmaz", "Ahmet"},
+		{"Ayşe Nur Kaya", "Ayşe Nur"},
+		{"Cher", "Cher"},
+		{"  Mehmet   Ali  Demir ", "Mehmet Ali"},
+		// the second given name is taken for a surname
+		{"Mehmet Ali", "Mehmet"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := givenPart(tt.fullname); got != tt.expected {
+			t.Errorf("givenPart(%q) = %q, expected %q", tt.fullname, got, tt.expected)
+		}
+	}
+}
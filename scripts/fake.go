@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// the models under this provider are answered by [FakeModel]
+const fakeProvider = "fake"
+
+// modes of a dictionary entry to simulate the ways a model fails
+const (
+	fakeFail      = "fail"      // the request containing the name fails for good
+	fakeFlaky     = "flaky"     // only the first request containing the name fails
+	fakeMalformed = "malformed" // the answer to the request is not valid JSON
+	fakeSkip      = "skip"      // the name is left out of the answer
+)
+
+type fakeEntry struct {
+	Gender, Mode string
+	Confidence   float64
+}
+
+// FakeDict is the answers of the fake models, of all of them and of each
+// by its name, which win over the former so the models of an ensemble can
+// disagree
+type FakeDict struct {
+	Shared map[string]fakeEntry
+	Models map[string]map[string]fakeEntry
+}
+
+func (d FakeDict) lookup(model, name string) (fakeEntry, bool) {
+	if e, ok := d.Models[model][name]; ok {
+		return e, true
+	}
+	e, ok := d.Shared[name]
+	return e, ok
+}
+
+// FakeModel answers from a dictionary instead of calling an API, so the
+// labeling can run offline and deterministically. Names missing in the
+// dictionary are answered as unknown.
+type FakeModel struct {
+	Dict    FakeDict
+	Latency time.Duration
+
+	mu     sync.Mutex
+	flaked map[string]bool
+}
+
+// ReadFakeDict reads the lines of "<name>\t<gender>[\t<mode>[\t<confidence>]]",
+// answered with the confidence of 1 when it is missing. The lines after a
+// "[<name>]" line are only answered by the fake/<name> model.
+func ReadFakeDict(path string) (FakeDict, error) {
+	dict := FakeDict{Shared: map[string]fakeEntry{}, Models: map[string]map[string]fakeEntry{}}
+	f, err := os.Open(path)
+	if err != nil {
+		return dict, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	section := dict.Shared
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if model, ok := strings.CutPrefix(s.Text(), "["); ok && strings.HasSuffix(model, "]") {
+			model = strings.TrimSuffix(model, "]")
+			if dict.Models[model] == nil {
+				dict.Models[model] = map[string]fakeEntry{}
+			}
+			section = dict.Models[model]
+			continue
+		}
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		e := fakeEntry{Gender: fields[1], Confidence: 1}
+		if len(fields) > 2 {
+			e.Mode = fields[2]
+		}
+		if len(fields) > 3 {
+			if e.Confidence, err = strconv.ParseFloat(fields[3], 64); err != nil {
+				return dict, fmt.Errorf("line %d: confidence: %w", line, err)
+			}
+		}
+		section[fields[0]] = e
+	}
+	if err := s.Err(); err != nil {
+		return dict, fmt.Errorf("scan: %w", err)
+	}
+	return dict, nil
+}
+
+var fakeNames = regexp.MustCompile(`NAMES: (\[.*\])`)
+
+func (fm *FakeModel) answer(model string, names []string) (string, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	for _, name := range names {
+		e, _ := fm.Dict.lookup(model, name)
+		switch e.Mode {
+		case fakeFail:
+			return "", core.NewError(core.INVALID_ARGUMENT, "fake: refusing %q", name)
+		case fakeFlaky:
+			// each model fails once
+			if !fm.flaked[model+"\x00"+name] {
+				fm.flaked[model+"\x00"+name] = true
+				return "", core.NewError(core.UNAVAILABLE, "fake: unavailable for %q", name)
+			}
+		}
+	}
+
+	a := Answer{Items: []LabeledName{}}
+	for _, name := range names {
+		e, ok := fm.Dict.lookup(model, name)
+		switch {
+		case e.Mode == fakeMalformed:
+			return `{"items": [{"name": "` + name, nil
+		case e.Mode == fakeSkip:
+			continue
+		case !ok:
+			a.Items = append(a.Items, LabeledName{Name: name, Gender: "unknown", Confidence: 1, Rationale: "not in the dictionary"})
+		default:
+			a.Items = append(a.Items, LabeledName{Name: name, Gender: e.Gender, Confidence: e.Confidence, Rationale: "from the dictionary"})
+		}
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+	return string(b), nil
+}
+
+// DefineModel registers the model as "fake/<name>"
+func (fm *FakeModel) DefineModel(g *genkit.Genkit, name string) ai.Model {
+	if fm.flaked == nil {
+		fm.flaked = map[string]bool{}
+	}
+	opts := &ai.ModelOptions{
+		Label: name,
+		Supports: &ai.ModelSupports{
+			Multiturn:   true,
+			SystemRole:  true,
+			Constrained: ai.ConstrainedSupportNone,
+		},
+	}
+	return genkit.DefineModel(g, fakeProvider+"/"+name, opts,
+		func(ctx context.Context, mr *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(fm.Latency):
+			}
+
+			prompt := strings.Builder{}
+			for _, m := range mr.Messages {
+				prompt.WriteString(m.Text())
+			}
+			match := fakeNames.FindStringSubmatch(prompt.String())
+			if match == nil {
+				return nil, core.NewError(core.INVALID_ARGUMENT, "fake: no names in the prompt")
+			}
+			names := []string{}
+			if err := json.Unmarshal([]byte(match[1]), &names); err != nil {
+				return nil, core.NewError(core.INVALID_ARGUMENT, "fake: parsing names: %v", err)
+			}
+
+			text, err := fm.answer(name, names)
+			if err != nil {
+				return nil, err
+			}
+			return &ai.ModelResponse{
+				Request:      mr,
+				Message:      ai.NewModelTextMessage(text),
+				FinishReason: ai.FinishReasonStop,
+				Usage: &ai.GenerationUsage{
+					InputTokens:  len(prompt.String()) / 4,
+					OutputTokens: len(text) / 4,
+					TotalTokens:  (len(prompt.String()) + len(text)) / 4,
+				},
+			}, nil
+		},
+	)
+}
@@ -8,16 +8,22 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
 	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core/api"
 	"github.com/firebase/genkit/go/genkit"
 	"github.com/firebase/genkit/go/plugins/googlegenai"
+	"main/internal/labels"
+	"main/internal/members"
+	"main/internal/normalize"
 )
 
 func timestamp() string {
@@ -25,16 +31,37 @@ func timestamp() string {
 }
 
 type Args struct {
+	Input             string
 	Start, End, Batch int
+	MinConfidence     float64
+	Model, Models     string
+	Prefilter         bool
+	Quorum            float64
+	Order             string
+	Shard             string
+	Prior             string
+	Frequencies       string
+	Resume            string        `json:"-"`
+	Workers, RPM, TPM int           `json:"-"`
+	Retries           int           `json:"-"`
+	Backoff           time.Duration `json:"-"`
+	OpenAIURL         string        `json:"-"`
+	Cache             string        `json:"-"`
+	Overrides         string        `json:"-"`
+	FakeDict          string        `json:"-"`
+	FakeLatency       time.Duration `json:"-"`
 }
 
 type Question struct {
+	Model       string   `json:"model,omitempty"` // empty for the default model
 	MemberNames []string `json:"member-names" jsonschema:"description=Claimed to be a human name"`
 }
 
 type LabeledName struct {
-	Name   string `json:"name" jsonschema:"description=Original name"`
-	Gender string `json:"gender" jsonschema:"enum=male,enum=female,enum=unisex,enum=unknown"`
+	Name       string  `json:"name" jsonschema:"description=Original name"`
+	Gender     string  `json:"gender" jsonschema:"enum=male,enum=female,enum=unisex,enum=unknown"`
+	Confidence float64 `json:"confidence" jsonschema:"minimum=0,maximum=1,description=Probability of the gender being right"`
+	Rationale  string  `json:"rationale" jsonschema:"description=One short sentence on why"`
 }
 
 type Answer struct {
@@ -42,48 +69,281 @@ type Answer struct {
 }
 
 type OutputFiles struct {
-	Male, Female io.WriteCloser
+	Male, Female    *os.File
+	Unisex, Unknown *os.File
+	Invalid         *os.File // unexpected gender values with the name
+	Answers         *os.File // every answer as JSON lines, before the threshold applied
+	Disagreements   *os.File // answers of each model for the names the ensemble disagreed
+	Failed          *os.File // quarantined names with the last error
+	Soft            *os.File // probability of each name being female as "<name>\t<p>" lines
+}
+
+func (o *OutputFiles) files() map[string]**os.File {
+	return map[string]**os.File{
+		"male.txt":            &o.Male,
+		"female.txt":          &o.Female,
+		"unisex.txt":          &o.Unisex,
+		"unknown.txt":         &o.Unknown,
+		"invalid.txt":         &o.Invalid,
+		"answers.jsonl":       &o.Answers,
+		"disagreements.jsonl": &o.Disagreements,
+		"failed.txt":          &o.Failed,
+		"soft.tsv":            &o.Soft,
+	}
+}
+
+// opens the files in append mode so a resumed run keeps the names of
+// the batches that are already journaled
+func openOutputFiles(dir string) (*OutputFiles, error) {
+	o := &OutputFiles{}
+	for name, f := range o.files() {
+		var err error
+		*f, err = os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			o.Close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return o, nil
+}
+
+func (o *OutputFiles) Sync() error {
+	for name, f := range o.files() {
+		if err := (*f).Sync(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (o *OutputFiles) Close() {
+	for _, f := range o.files() {
+		if *f != nil {
+			(*f).Close()
+		}
+	}
 }
 
 func percentage(current, total int) int {
 	return int(100 * float64(current) / float64(total))
 }
 
+// memberCount sums the member counts of the names
+func memberCount(counts map[string]int, names []string) int {
+	n := 0
+	for _, name := range names {
+		n += counts[name]
+	}
+	return n
+}
+
 var prompt = `
 You are a careful name annotator. For each NAME in NAMES, output STRICT JSON:
-{"items":[{"name":"<original name>","gender":"<male|female|unisex|unknown>"}...]}
+{"items":[{"name":"<original name>","gender":"<male|female|unisex|unknown>","confidence":<0..1>,"rationale":"<short reason>"}...]}
 
 Rules:
 - Prefer "unisex" if the name is commonly used by multiple genders in any major locale.
+- A NAME may be a compound of given names (e.g., "ayşe nur", "mehmet ali"); label the compound as a whole, as its gender may differ from its first name.
 - Use "unknown" for initials, handles, organization names, or if confidence is low.
+- Set "confidence" to the probability of the gender being right, from 0 to 1.
+- Keep "rationale" to a single short sentence.
 - Consider cultural/linguistic contexts (e.g., Turkish, Arabic, Persian, Slavic, Western European).
 - Return STRICT JSON and nothing else.
 
 NAMES: {{.}}
 `
 
+// readNames accepts either a newline separated list of names or the
+// frequencies table written by the ingest command
+func readNames(path string) ([]string, error) {
+	if filepath.Ext(path) == ".tsv" {
+		f, err := members.ReadFrequencies(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading frequencies: %w", err)
+		}
+		return f.Names(), nil
+	}
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return strings.Split(string(f), "\n"), nil
+}
+
+// readCounts returns the number of members having each name across all
+// communities, by the canonical form of the name
+func readCounts(path string) (map[string]int, error) {
+	f, err := members.ReadFrequencies(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading frequencies: %w", err)
+	}
+	counts := map[string]int{}
+	for _, name := range f.Names() {
+		counts[normalize.Name(name)] += f.Total(name)
+	}
+	return counts, nil
+}
+
 func Main() error {
 	args := Args{}
+	flag.StringVar(&args.Input, "input", "labels/uniq-names.txt", "newline separated list of names to label, or the name-frequencies.tsv of ingest")
 	flag.IntVar(&args.Start, "start", 0, "start index")
-	flag.IntVar(&args.End, "end", -1, "start index")
+	flag.IntVar(&args.End, "end", -1, "end index, exclusive (-1 for all)")
 	flag.IntVar(&args.Batch, "batch", 10, "batch")
+	flag.StringVar(&args.Model, "model", "googleai/gemini-2.5-flash", "model to label with, either googleai/<name> or openai/<name>")
+	flag.StringVar(&args.OpenAIURL, "openai-url", "", "base URL of the OpenAI-compatible server for openai/<name> models, eg. http://localhost:8080/v1")
+	flag.BoolVar(&args.Prefilter, "prefilter", true, "label the tokens that can't be a given name unknown without asking the model")
+	flag.StringVar(&args.Models, "models", "", "comma separated models with optional weights to vote, eg. googleai/gemini-2.5-flash=2,googleai/gemini-2.5-pro")
+	flag.Float64Var(&args.Quorum, "quorum", 0.6, "share of the total weight the voted label needs, otherwise the name is labeled unknown")
+	flag.Float64Var(&args.MinConfidence, "min-confidence", 0, "male and female answers below this confidence are labeled unknown")
+	flag.StringVar(&args.Order, "order", "input", "order of labeling, either input (the order of the file) or frequency (the names of the most members first)")
+	flag.StringVar(&args.Frequencies, "frequencies", "labels/name-frequencies.tsv", "member counts of the names written by ingest, for --order frequency and the coverage in progress")
+	flag.StringVar(&args.Shard, "shard", "", "label only the i'th of n partitions of the names by hash, eg. 0/4 (empty for all)")
+	flag.StringVar(&args.Overrides, "overrides", "labels/overrides.tsv", "labels fixed by hand as \"<name>\\t<gender>\\t<note>\\t<author>\" lines, never sent to the model")
+	flag.StringVar(&args.Prior, "prior", "", "probabilities of the names being female as \"<name>\\t<p>\" lines, for the soft labels of the names the models can't tell")
+	flag.StringVar(&args.Resume, "resume", "", "run directory of an interrupted run to continue")
+	flag.IntVar(&args.Workers, "workers", 1, "number of batches labeled in parallel")
+	flag.IntVar(&args.RPM, "rpm", 0, "requests per minute limit (0 for unlimited)")
+	flag.IntVar(&args.TPM, "tpm", 0, "tokens per minute limit (0 for unlimited)")
+	flag.StringVar(&args.Cache, "cache", "labels/cache", "directory of the answers cached by model and prompt (empty to disable)")
+	flag.IntVar(&args.Retries, "retries", 5, "attempts for a batch before splitting it")
+	flag.DurationVar(&args.Backoff, "backoff", time.Second, "wait before the first retry, doubled for each next")
+	flag.StringVar(&args.FakeDict, "fake-dict", "", "dictionary of \"<name>\\t<gender>[\\t<mode>[\\t<confidence>]]\" lines answering for fake/<name> models, the lines after \"[<name>]\" for that model only")
+	flag.DurationVar(&args.FakeLatency, "fake-latency", 0, "delay of each answer of fake/<name> models")
 	flag.Parse()
 
+	return label(args)
+}
+
+// label runs the labeling for the arguments, writing into a new run
+// directory under labels/ or the resumed one
+func label(args Args) error {
 	t, err := template.New("").Parse(prompt)
 	if err != nil {
 		return fmt.Errorf("parsing prompt template: %w", err)
 	}
 
-	api := &googlegenai.GoogleAI{
-		APIKey: os.Getenv("GEMINI_API_KEY"),
+	dir := args.Resume
+	if dir != "" {
+		saved, err := loadArgs(filepath.Join(dir, "args.json"))
+		if err != nil {
+			return fmt.Errorf("loading args of the run: %w", err)
+		}
+		args.Input, args.Start, args.End, args.Batch = saved.Input, saved.Start, saved.End, saved.Batch
+		args.MinConfidence, args.Model, args.Models, args.Quorum = saved.MinConfidence, saved.Model, saved.Models, saved.Quorum
+		args.Prefilter, args.Order, args.Frequencies, args.Shard, args.Prior = saved.Prefilter, saved.Order, saved.Frequencies, saved.Shard, saved.Prior
+		fmt.Printf("resuming: %s (input=%s start=%d end=%d batch=%d min-confidence=%g model=%s models=%q quorum=%g order=%s shard=%s)\n",
+			dir, args.Input, args.Start, args.End, args.Batch, args.MinConfidence, args.Model, args.Models, args.Quorum, args.Order, args.Shard)
+	}
+
+	input, err := readNames(args.Input)
+	if err != nil {
+		return fmt.Errorf("reading names: %w", err)
+	}
+
+	shard, err := ParseShard(args.Shard)
+	if err != nil {
+		return fmt.Errorf("parsing shard: %w", err)
+	}
+
+	// names are labeled in their canonical form, which is what the ratio
+	// calculation looks up
+	memberNames := []string{}
+	seen := map[string]bool{}
+	for _, name := range input {
+		name = normalize.Name(name)
+		if name != "" && !seen[name] && shard.Contains(name) {
+			memberNames = append(memberNames, name)
+			seen[name] = true
+		}
+	}
+
+	// member counts are optional for the input order, the progress is
+	// reported by the names then
+	var counts map[string]int
+	switch args.Order {
+	case "", "input":
+		if _, err := os.Stat(args.Frequencies); args.Frequencies != "" && err == nil {
+			if counts, err = readCounts(args.Frequencies); err != nil {
+				return fmt.Errorf("reading member counts: %w", err)
+			}
+		}
+	case "frequency":
+		if counts, err = readCounts(args.Frequencies); err != nil {
+			return fmt.Errorf("reading member counts: %w", err)
+		}
+		// ties stay in the input order
+		slices.SortStableFunc(memberNames, func(a, b string) int {
+			return counts[b] - counts[a]
+		})
+	default:
+		return fmt.Errorf("unknown order: %q", args.Order)
+	}
+
+	ensemble := Ensemble{Quorum: args.Quorum}
+	ensemble.Members, err = ParseEnsemble(args.Models)
+	if err != nil {
+		return fmt.Errorf("parsing models: %w", err)
+	}
+	if len(ensemble.Members) == 0 {
+		ensemble.Members = []EnsembleMember{{Model: args.Model, Weight: 1}}
+	}
+
+	// the Google AI plugin panics without a key, so it is only added when
+	// one of the models needs it
+	plugins := []api.Plugin{}
+	openai, fake := []string{}, []string{}
+	for _, m := range ensemble.Members {
+		provider, name, _ := strings.Cut(m.Model, "/")
+		switch provider {
+		case "googleai":
+			if len(plugins) == 0 {
+				plugins = append(plugins, &googlegenai.GoogleAI{
+					APIKey: os.Getenv("GEMINI_API_KEY"),
+				})
+			}
+		case openaiProvider:
+			if args.OpenAIURL == "" {
+				return fmt.Errorf("--openai-url is required for %q", m.Model)
+			}
+			openai = append(openai, name)
+		case fakeProvider:
+			if args.FakeDict == "" {
+				return fmt.Errorf("--fake-dict is required for %q", m.Model)
+			}
+			fake = append(fake, name)
+		default:
+			return fmt.Errorf("unknown provider for %q", m.Model)
+		}
 	}
 
 	g := genkit.Init(
 		context.Background(),
-		genkit.WithPlugins(api),
-		genkit.WithDefaultModel("googleai/gemini-2.5-flash"),
+		genkit.WithPlugins(plugins...),
+		genkit.WithDefaultModel(args.Model),
 	)
 
+	oc := &OpenAICompatible{
+		BaseURL: args.OpenAIURL,
+		APIKey:  os.Getenv("OPENAI_API_KEY"),
+	}
+	for _, name := range slices.Compact(slices.Sorted(slices.Values(openai))) {
+		oc.DefineModel(g, name)
+	}
+
+	if len(fake) > 0 {
+		fm := &FakeModel{Latency: args.FakeLatency}
+		fm.Dict, err = ReadFakeDict(args.FakeDict)
+		if err != nil {
+			return fmt.Errorf("reading fake dictionary: %w", err)
+		}
+		for _, name := range slices.Compact(slices.Sorted(slices.Values(fake))) {
+			fm.DefineModel(g, name)
+		}
+	}
+
+	l := NewLimiter(args.RPM, args.TPM)
+
 	flow := genkit.DefineFlow(g, "AnswerGeneratorFlow",
 		func(ctx context.Context, q *Question) (*Answer, error) {
 			names := bytes.NewBuffer([]byte{})
@@ -91,10 +351,21 @@ func Main() error {
 				return nil, fmt.Errorf("encoding question into json: %w", err)
 			}
 			prompt := bytes.NewBufferString("")
-			if err = t.Execute(prompt, names); err != nil {
+			if err := t.Execute(prompt, names); err != nil {
 				return nil, fmt.Errorf("templating the prompt: %w", err)
 			}
-			a, _, err := genkit.GenerateData[Answer](ctx, g, ai.WithPrompt(prompt.String()))
+			s, err := l.Wait(ctx, estimateTokens(prompt.String(), len(q.MemberNames)))
+			if err != nil {
+				return nil, fmt.Errorf("waiting for the rate limiter: %w", err)
+			}
+			opts := []ai.GenerateOption{ai.WithPrompt(prompt.String())}
+			if q.Model != "" {
+				opts = append(opts, ai.WithModelName(q.Model))
+			}
+			a, resp, err := genkit.GenerateData[Answer](ctx, g, opts...)
+			if resp != nil && resp.Usage != nil && resp.Usage.TotalTokens > 0 {
+				l.Settle(s, resp.Usage.TotalTokens)
+			}
 			if err != nil {
 				return nil, fmt.Errorf("genkit.GenerateData: %w", err)
 			}
@@ -102,86 +373,386 @@ func Main() error {
 		},
 	)
 
-	f, err := os.ReadFile("labels/uniq-names.txt")
-	if err != nil {
-		return fmt.Errorf("read: %w", err)
+	// the indices are of the names left after the deduplication and the
+	// sharding, which can be fewer than the lines of the input
+	if args.End == -1 || args.End > len(memberNames) {
+		args.End = len(memberNames)
+	}
+	if args.Start < 0 || args.Start > args.End {
+		return fmt.Errorf("start %d is out of the %d names to label", args.Start, args.End)
+	}
+	if args.Batch < 1 {
+		return fmt.Errorf("expected a batch of at least 1 name, got %d", args.Batch)
+	}
+	if args.Workers < 1 {
+		return fmt.Errorf("expected at least 1 worker, got %d", args.Workers)
 	}
 
-	o := OutputFiles{
-		Male:   nil,
-		Female: nil,
+	if dir == "" {
+		dir = filepath.Join("labels", timestamp())
+		if err = os.Mkdir(dir, 0700); err != nil {
+			return fmt.Errorf("mkdir: %w", err)
+		}
+		if err = saveArgs(filepath.Join(dir, "args.json"), args); err != nil {
+			return fmt.Errorf("saving args: %w", err)
+		}
 	}
 
-	now := timestamp()
-	if err = os.Mkdir(filepath.Join("labels", now), 0700); err != nil {
-		return fmt.Errorf("mkdir: %w", err)
+	overrides, err := labels.ReadOverrides(args.Overrides)
+	if err != nil {
+		return fmt.Errorf("reading overrides: %w", err)
+	}
+	prior := labels.Soft{}
+	if args.Prior != "" {
+		if prior, err = labels.ReadSoft(args.Prior); err != nil {
+			return fmt.Errorf("reading prior: %w", err)
+		}
 	}
 
-	o.Male, err = os.Create(filepath.Join("labels", now, "male.txt"))
+	if args.Cache != "" {
+		ensemble.Cache, err = OpenCache(args.Cache, prompt)
+		if err != nil {
+			return fmt.Errorf("opening cache: %w", err)
+		}
+		defer ensemble.Cache.Close()
+		fmt.Println("cached answers:", ensemble.Cache.Len())
+	}
+
+	o, err := openOutputFiles(dir)
 	if err != nil {
-		return fmt.Errorf("create: %w", err)
+		return fmt.Errorf("opening output files: %w", err)
 	}
-	defer o.Male.Close()
+	defer o.Close()
 
-	o.Female, err = os.Create(filepath.Join("labels", now, "female.txt"))
+	j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
 	if err != nil {
-		return fmt.Errorf("create: %w", err)
+		return fmt.Errorf("opening journal: %w", err)
 	}
-	defer o.Female.Close()
+	defer j.Close()
 
-	included, excluded := 0, 0
+	included, excluded, failed, demoted, disagreed, cached, prefiltered, overridden := 0, 0, 0, 0, 0, 0, 0, 0
+	saved := 0 // requests
+	reconciled := ReconcileStats{}
 	pct := -1
+	covered, population := 0, 0 // members having the labeled names, of all
 	batch := 0
 
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Println("recovered:", r)
 		}
-		fmt.Println("total :", included+excluded)
+		fmt.Println("total :", included+excluded+failed)
 		fmt.Println("incl. :", included)
 		fmt.Println("excl. :", excluded)
+		fmt.Println("fail. :", failed)
+		fmt.Println("demot.:", demoted, "below min-confidence", args.MinConfidence)
+		fmt.Println("disag.:", disagreed)
+		fmt.Println("cache :", cached)
+		fmt.Println("pref. :", prefiltered, "saving", saved, "requests")
+		fmt.Println("overr.:", overridden)
+		fmt.Println("renam.:", reconciled.Renamed)
+		fmt.Println("dupl. :", reconciled.Duplicated)
+		fmt.Println("rejec.:", reconciled.Rejected)
+		fmt.Println("requ. :", reconciled.Requeued)
 		fmt.Println("pct.  :", pct)
+		if counts != nil {
+			fmt.Println("membr.:", covered, "of", population)
+		}
 		fmt.Println("batch :", batch)
 	}()
 
-	memberNames := strings.Split(string(f), "\n")
-	if args.End == -1 {
-		args.End = len(memberNames)
-	}
+	// the coverage is of all the members, so a run cut short tells how
+	// much of the communities it labeled
+	population = memberCount(counts, memberNames)
 	memberNames = memberNames[args.Start:args.End]
+	size := len(memberNames)
+	batchCount := func(names int) int {
+		return (names + args.Batch - 1) / args.Batch
+	}
 
-	for batch = 0; batch*args.Batch < len(memberNames); batch++ {
-		var (
-			from = min(len(memberNames), args.Batch*(batch))
-			to   = min(len(memberNames), args.Batch*(batch+1))
-		)
-		q := &Question{
-			MemberNames: memberNames[from:to],
+	// the tokens that can't be a given name are labeled before batching,
+	// under the batch -1 of the journal
+	if args.Prefilter {
+		// the prefiltered names are journaled, as the overrides left out
+		// of the prefilter would otherwise move the batches of a resumed
+		// run when the overrides file changes
+		e, done := j.Done(-1)
+		journaled := map[string]bool{}
+		for _, name := range e.Names {
+			journaled[name] = true
 		}
-		a, err := flow.Run(context.Background(), q)
-		if err != nil {
-			return fmt.Errorf("flow.Run: %v", err)
+		kept, unknown := []string{}, []LabeledName{}
+		for _, name := range memberNames {
+			reason := ""
+			if done && len(e.Names) == e.Prefiltered {
+				if journaled[name] {
+					reason = "journaled"
+				}
+			} else if _, ok := overrides[name]; !ok {
+				// the labels fixed by hand win over the prefilter
+				reason = prefilter(name)
+			}
+			if reason != "" {
+				unknown = append(unknown, LabeledName{Name: name, Gender: "unknown", Confidence: 1, Rationale: "prefilter: " + reason})
+			} else {
+				kept = append(kept, name)
+			}
 		}
+		saved = (batchCount(len(memberNames)) - batchCount(len(kept))) * len(ensemble.Members)
+		memberNames = kept
 
-		for _, item := range a.Items {
+		if !done {
+			names := []string{}
+			for _, item := range unknown {
+				if err := json.NewEncoder(o.Answers).Encode(item); err != nil {
+					return fmt.Errorf("writing answer: %w", err)
+				}
+				fmt.Fprintln(o.Unknown, item.Name)
+				names = append(names, item.Name)
+			}
+			if err := o.Sync(); err != nil {
+				return fmt.Errorf("syncing output files: %w", err)
+			}
+			e = JournalEntry{Batch: -1, Excluded: len(unknown), Prefiltered: len(unknown), Names: names}
+			if err := j.Commit(e); err != nil {
+				return fmt.Errorf("journaling prefiltered names: %w", err)
+			}
+		}
+		excluded += e.Excluded
+		prefiltered += e.Prefiltered
+		for _, item := range unknown {
+			covered += counts[item.Name]
+		}
+	}
+
+	// write writes the outcome of a batch into the output files, and
+	// returns its journal entry to commit
+	write := func(batch int, r *Labeled) (JournalEntry, error) {
+		e := JournalEntry{Batch: batch, ReconcileStats: r.Stats, Cached: r.Cached, Overridden: r.Overridden}
+		for _, f := range r.Failed {
+			fmt.Printf("WARNING: quarantined %q: %v\n", f.Name, f.Err)
+			fmt.Fprintln(o.Failed, f)
+			e.Failed += 1
+		}
+		for _, d := range r.Disagreements {
+			if err := json.NewEncoder(o.Disagreements).Encode(d); err != nil {
+				return e, fmt.Errorf("writing disagreement: %w", err)
+			}
+			e.Disagreed += 1
+		}
+		for _, item := range r.Answer.Items {
+			if err := json.NewEncoder(o.Answers).Encode(item); err != nil {
+				return e, fmt.Errorf("writing answer: %w", err)
+			}
+			// the soft labels are kept whatever the threshold
+			p, ok := r.PFemale[item.Name]
+			if !ok {
+				p, ok = prior[item.Name]
+			}
+			if ok {
+				fmt.Fprintf(o.Soft, "%s\t%s\n", item.Name, strconv.FormatFloat(p, 'g', 4, 64))
+			}
+			if (item.Gender == "male" || item.Gender == "female") && item.Confidence < args.MinConfidence {
+				item.Gender = "unknown"
+				e.Demoted += 1
+			}
 			switch item.Gender {
 			case "male":
 				fmt.Fprintln(o.Male, item.Name)
-				included += 1
+				e.Included += 1
 			case "female":
 				fmt.Fprintln(o.Female, item.Name)
-				included += 1
-			case "unisex", "unknown":
-				excluded += 1
+				e.Included += 1
+			case "unisex":
+				fmt.Fprintln(o.Unisex, item.Name)
+				e.Excluded += 1
+			case "unknown":
+				fmt.Fprintln(o.Unknown, item.Name)
+				e.Excluded += 1
 			default:
 				fmt.Printf("WARNING: unexpected answer from LLM: %q for %q\n", item.Gender, item.Name)
-				excluded += 1
+				fmt.Fprintf(o.Invalid, "%s\t%s\n", item.Gender, item.Name)
+				e.Excluded += 1
 			}
 		}
+		if err := o.Sync(); err != nil {
+			return e, fmt.Errorf("syncing output files: %w", err)
+		}
+		return e, nil
+	}
+
+	tally := func(e JournalEntry) {
+		included += e.Included
+		excluded += e.Excluded
+		failed += e.Failed
+		demoted += e.Demoted
+		disagreed += e.Disagreed
+		cached += e.Cached
+		overridden += e.Overridden
+		reconciled.Add(e.ReconcileStats)
+	}
 
-		if pct2 := percentage(included+excluded, len(memberNames)); pct2 > pct {
-			pct = pct2
-			fmt.Printf("progress: %%%d\n", pct)
+	// the names every model has a cached answer for are labeled before
+	// batching, under the batch -2 of the journal, so the batches only
+	// carry the names to ask. The names are journaled, as the cache grows
+	// while the run goes on and a resumed run has to batch the same names.
+	// A run resumed with a cache it didn't have keeps batching every name.
+	e, ok := j.Done(-2)
+	if _, pre := j.Done(-1); !ok && ensemble.Cache != nil && (j.Len() == 0 || (pre && j.Len() == 1)) {
+		// the overrides are applied in the batches
+		asked := slices.DeleteFunc(slices.Clone(memberNames), func(name string) bool {
+			_, ok := overrides[name]
+			return ok
+		})
+		l, hits := ensemble.FromCache(asked)
+		if e, err = write(-2, l); err != nil {
+			return err
+		}
+		e.Names = hits
+		if err := j.Commit(e); err != nil {
+			return fmt.Errorf("journaling cached names: %w", err)
+		}
+		ok = true
+	}
+	if ok {
+		hits := map[string]bool{}
+		for _, name := range e.Names {
+			hits[name] = true
+		}
+		memberNames = slices.DeleteFunc(memberNames, func(name string) bool { return hits[name] })
+		tally(e)
+		covered += memberCount(counts, e.Names)
+	}
+
+	total := batchCount(len(memberNames))
+	batchNames := func(b int) []string {
+		return memberNames[min(len(memberNames), args.Batch*b):min(len(memberNames), args.Batch*(b+1))]
+	}
+
+	// the journal is only read by this goroutine, so the batches to label
+	// are listed before the workers start
+	todo := []int{}
+	for b := range total {
+		if _, ok := j.Done(b); !ok {
+			todo = append(todo, b)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		batch int
+		*Labeled
+		err error
+	}
+
+	policy := RetryPolicy{
+		Attempts: args.Retries,
+		Base:     args.Backoff,
+		Max:      time.Minute,
+	}
+
+	batches := make(chan int)
+	results := make(chan result)
+
+	go func() {
+		defer close(batches)
+		for _, b := range todo {
+			select {
+			case batches <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for range args.Workers {
+		wg.Go(func() {
+			for b := range batches {
+				// the overridden names stay in their batches, so a
+				// changed overrides file doesn't move the batches of
+				// a resumed run
+				names, fixed := []string{}, []LabeledName{}
+				for _, name := range batchNames(b) {
+					if ov, ok := overrides[name]; ok {
+						fixed = append(fixed, LabeledName{Name: name, Gender: ov.Gender, Confidence: 1, Rationale: fmt.Sprintf("override by %s: %s", ov.Author, ov.Note)})
+					} else {
+						names = append(names, name)
+					}
+				}
+				l, err := ensemble.Label(ctx, flow.Run, policy, names)
+				if err == nil {
+					l.Answer.Items = append(l.Answer.Items, fixed...)
+					l.Overridden = len(fixed)
+					for _, item := range fixed {
+						if p, ok := labels.PFemale(item.Gender); ok {
+							l.PFemale[item.Name] = p
+						}
+					}
+				}
+				select {
+				case results <- result{batch: b, Labeled: l, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// answers arrive in any order; they are kept until all the batches
+	// before them are written, so the output files don't depend on timing
+	pending := map[int]result{}
+	flush := func() error {
+		for ; batch < total; batch++ {
+			if e, ok := j.Done(batch); ok {
+				tally(e)
+				covered += memberCount(counts, batchNames(batch))
+				continue
+			}
+			r, ok := pending[batch]
+			if !ok {
+				return nil
+			}
+			delete(pending, batch)
+
+			e, err := write(batch, r.Labeled)
+			if err != nil {
+				return err
+			}
+			if err := j.Commit(e); err != nil {
+				return fmt.Errorf("journaling batch %d: %w", batch, err)
+			}
+			tally(e)
+			covered += memberCount(counts, batchNames(batch))
+
+			if pct2 := percentage(included+excluded+failed, size); pct2 > pct {
+				pct = pct2
+				if counts != nil {
+					fmt.Printf("progress: %%%d of names, %%%d of members\n", pct, percentage(covered, population))
+				} else {
+					fmt.Printf("progress: %%%d\n", pct)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	for r := range results {
+		if r.err != nil {
+			return fmt.Errorf("flow.Run: batch %d: %v", r.batch, r.err)
+		}
+		pending[r.batch] = r
+		if err := flush(); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,716 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+var testNames = []string{
+	"ahmet", "ayşe", "deniz",
+	"mehmet", "refused", "zeynep",
+	"can", "broken", "skipped",
+	"xkcd",
+}
+
+var testDict = `ahmet	male
+ayşe	female
+deniz	unisex
+mehmet	male
+refused	male	fail
+zeynep	female
+can	male	flaky
+broken	male	malformed
+skipped	female	skip
+`
+
+func testArgs() Args {
+	return Args{
+		Input:    "labels/uniq-names.txt",
+		End:      -1,
+		Batch:    3,
+		Model:    "fake/dict",
+		Quorum:   0.6,
+		Workers:  3,
+		Retries:  3,
+		Backoff:  time.Millisecond,
+		FakeDict: "dict.tsv",
+	}
+}
+
+func setup(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("labels", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("labels/uniq-names.txt", []byte(strings.Join(testNames, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("dict.tsv", []byte(testDict), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runDir(t *testing.T) string {
+	dirs, err := filepath.Glob("labels/[0-9]*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 {
+		t.Fatalf("expected a single run directory, got %v", dirs)
+	}
+	return dirs[0]
+}
+
+func lines(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := []string{}
+	for _, l := range strings.Split(string(b), "\n") {
+		if l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+func firstColumn(ls []string) []string {
+	c := []string{}
+	for _, l := range ls {
+		c = append(c, strings.Split(l, "\t")[0])
+	}
+	return c
+}
+
+func TestLabel_fakeModel(t *testing.T) {
+	setup(t)
+	if err := label(testArgs()); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+
+	tcs := map[string][]string{
+		"male.txt":    {"ahmet", "mehmet", "can"},
+		"female.txt":  {"ayşe", "zeynep"},
+		"unisex.txt":  {"deniz"},
+		"unknown.txt": {"xkcd"},
+		"invalid.txt": {},
+	}
+	for file, expected := range tcs {
+		t.Run(file, func(t *testing.T) {
+			if got := lines(t, filepath.Join(dir, file)); !slices.Equal(got, expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+
+	t.Run("soft.tsv", func(t *testing.T) {
+		// the unknown answer of xkcd tells nothing
+		expected := []string{"ahmet\t0", "ayşe\t1", "deniz\t0.5", "mehmet\t0", "zeynep\t1", "can\t0"}
+		got := lines(t, filepath.Join(dir, "soft.tsv"))
+		slices.Sort(got)
+		slices.Sort(expected)
+		if !slices.Equal(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("failed.txt", func(t *testing.T) {
+		expected := []string{"refused", "broken", "skipped"}
+		if got := firstColumn(lines(t, filepath.Join(dir, "failed.txt"))); !slices.Equal(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer j.Close()
+		if j.Len() != 4 {
+			t.Fatalf("expected 4 batches, got %d", j.Len())
+		}
+		included, excluded, failed := 0, 0, 0
+		for b := range 4 {
+			e, _ := j.Done(b)
+			included += e.Included
+			excluded += e.Excluded
+			failed += e.Failed
+		}
+		if included != 5 || excluded != 2 || failed != 3 {
+			t.Errorf("expected 5/2/3, got %d/%d/%d", included, excluded, failed)
+		}
+		if e, _ := j.Done(2); e.Requeued != 2 {
+			t.Errorf("expected the skipped name to be requeued twice, got %d", e.Requeued)
+		}
+	})
+}
+
+func TestLabel_resume(t *testing.T) {
+	setup(t)
+	args := testArgs()
+	args.Cache = "" // answers should come from the journal, not the cache
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+	male := lines(t, filepath.Join(dir, "male.txt"))
+
+	args.Resume = dir
+	if err := label(args); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if got := lines(t, filepath.Join(dir, "male.txt")); !slices.Equal(got, male) {
+		t.Errorf("expected resume to write nothing, got %v", got)
+	}
+}
+
+func TestLabel_cache(t *testing.T) {
+	setup(t)
+	args := testArgs()
+	args.Cache = "labels/cache"
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	first := runDir(t)
+
+	// a model without the dictionary would answer everything as unknown
+	if err := os.WriteFile("dict.tsv", []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(first, "prev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+	expected := []string{"ahmet", "mehmet", "can"}
+	if got := lines(t, filepath.Join(dir, "male.txt")); !slices.Equal(got, expected) {
+		t.Errorf("expected %v from the cache, got %v", expected, got)
+	}
+
+	// the cached names are labeled before batching, so only the misses
+	// are batched
+	j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	e, ok := j.Done(-2)
+	if !ok || !slices.Contains(e.Names, "ahmet") || slices.Contains(e.Names, "refused") {
+		t.Fatalf("expected the batch -2 with the cached names, got %v", e)
+	}
+	if e.Cached != len(e.Names) {
+		t.Errorf("expected an answer cached per name, got %d for %d", e.Cached, len(e.Names))
+	}
+	misses := len(testNames) - len(e.Names)
+	batches := (misses + args.Batch - 1) / args.Batch
+	if _, ok := j.Done(batches - 1); !ok {
+		t.Errorf("expected %d batches of the %d misses", batches, misses)
+	}
+	if _, ok := j.Done(batches); ok {
+		t.Errorf("expected only %d batches of the %d misses", batches, misses)
+	}
+}
+
+// writeInput replaces the names and the dictionary of [setup]
+func writeInput(t *testing.T, names []string, dict string) {
+	if err := os.WriteFile("labels/uniq-names.txt", []byte(strings.Join(names, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("dict.tsv", []byte(dict), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLabel_ensemble(t *testing.T) {
+	setup(t)
+	writeInput(t, []string{"ahmet", "zeynep", "deniz", "mehmet"}, `ahmet	male
+zeynep	female
+deniz	unisex
+mehmet	male
+[a]
+mehmet	male	skip
+[b]
+zeynep	male
+mehmet	female
+[c]
+ahmet	female
+zeynep	male
+mehmet	unisex
+`)
+	args := testArgs()
+	args.Models = "fake/a=3,fake/b,fake/c"
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+
+	// zeynep wins by the weight of a against b and c, and mehmet gets
+	// only 1 of the 5 votes without a
+	tcs := map[string][]string{
+		"male.txt":    {"ahmet"},
+		"female.txt":  {"zeynep"},
+		"unisex.txt":  {"deniz"},
+		"unknown.txt": {"mehmet"},
+		"soft.tsv":    {"ahmet\t0.2", "zeynep\t0.6", "deniz\t0.5", "mehmet\t0.75"},
+	}
+	for file, expected := range tcs {
+		if got := lines(t, filepath.Join(dir, file)); !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", file, expected, got)
+		}
+	}
+
+	disagreed := []string{}
+	for _, line := range lines(t, filepath.Join(dir, "disagreements.jsonl")) {
+		d := Disagreement{}
+		if err := json.Unmarshal([]byte(line), &d); err != nil {
+			t.Fatal(err)
+		}
+		disagreed = append(disagreed, d.Name)
+		if d.Name == "zeynep" && (d.Gender != "female" || d.Share != 0.6 || d.Answers["fake/b"].Gender != "male") {
+			t.Errorf("unexpected disagreement %+v", d)
+		}
+	}
+	if expected := []string{"ahmet", "zeynep", "mehmet"}; !slices.Equal(disagreed, expected) {
+		t.Errorf("expected the disagreements on %v, got %v", expected, disagreed)
+	}
+}
+
+func TestLabel_minConfidence(t *testing.T) {
+	setup(t)
+	writeInput(t, []string{"ahmet", "zeynep", "deniz"}, "ahmet\tmale\t\t0.9\nzeynep\tfemale\t\t0.6\ndeniz\tunisex\t\t0.4\n")
+	args := testArgs()
+	args.MinConfidence = 0.7
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+
+	// unisex is not demoted, and the soft labels are kept whatever the
+	// threshold
+	tcs := map[string][]string{
+		"male.txt":    {"ahmet"},
+		"female.txt":  nil,
+		"unisex.txt":  {"deniz"},
+		"unknown.txt": {"zeynep"},
+		"soft.tsv":    {"ahmet\t0.1", "zeynep\t0.6", "deniz\t0.5"},
+	}
+	for file, expected := range tcs {
+		if got := lines(t, filepath.Join(dir, file)); !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", file, expected, got)
+		}
+	}
+
+	j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if e, _ := j.Done(0); e.Demoted != 1 || e.Included != 1 {
+		t.Errorf("expected zeynep demoted, got %+v", e)
+	}
+}
+
+func TestLabel_prefilter(t *testing.T) {
+	setup(t)
+	args := testArgs()
+	args.Prefilter = true
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+
+	if got := lines(t, filepath.Join(dir, "unknown.txt")); !slices.Equal(got, []string{"xkcd"}) {
+		t.Errorf("expected xkcd to be prefiltered, got %v", got)
+	}
+	j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if e, ok := j.Done(-1); !ok || e.Prefiltered != 1 {
+		t.Errorf("expected the batch -1 with a prefiltered name, got %v", e)
+	}
+	if _, ok := j.Done(3); ok {
+		t.Errorf("expected 3 batches left to ask")
+	}
+}
+
+func TestLabel_frequencyOrder(t *testing.T) {
+	setup(t)
+	tsv := "name\ttotal\tx\nahmet\t1\t1\nayşe\t5\t5\ndeniz\t1\t1\nzeynep\t3\t3\nmehmet\t2\t2\n"
+	if err := os.WriteFile("labels/name-frequencies.tsv", []byte(tsv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	args := testArgs()
+	args.Order = "frequency"
+	args.Frequencies = "labels/name-frequencies.tsv"
+	args.End = 3
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+
+	got := append(lines(t, filepath.Join(dir, "female.txt")), lines(t, filepath.Join(dir, "male.txt"))...)
+	expected := []string{"ayşe", "zeynep", "mehmet"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected the most common names %v, got %v", expected, got)
+	}
+}
+
+func TestLabel_shards(t *testing.T) {
+	setup(t)
+	labeled := []string{}
+	for i, shard := range []string{"0/2", "1/2"} {
+		args := testArgs()
+		args.Shard = shard
+		if err := label(args); err != nil {
+			t.Fatalf("label %s: %v", shard, err)
+		}
+		dir := runDir(t)
+		for _, file := range []string{"male.txt", "female.txt", "unisex.txt", "unknown.txt", "failed.txt"} {
+			labeled = append(labeled, firstColumn(lines(t, filepath.Join(dir, file)))...)
+		}
+		if err := os.Rename(dir, fmt.Sprintf("shard-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	slices.Sort(labeled)
+	expected := slices.Sorted(slices.Values(testNames))
+	if !slices.Equal(labeled, expected) {
+		t.Errorf("expected the shards to cover %v once, got %v", expected, labeled)
+	}
+}
+
+func TestLabel_overrides(t *testing.T) {
+	setup(t)
+	// the fake model fails the requests with "refused", so it is never asked
+	overrides := "name\tgender\tnote\tauthor\nrefused\tfemale\tnot a refusal\ttester\nAyşe\tmale\tjust a test\ttester\n"
+	if err := os.WriteFile("labels/overrides.tsv", []byte(overrides), 0600); err != nil {
+		t.Fatal(err)
+	}
+	args := testArgs()
+	args.Overrides = "labels/overrides.tsv"
+	if err := label(args); err != nil {
+		t.Fatalf("label: %v", err)
+	}
+	dir := runDir(t)
+
+	tcs := map[string][]string{
+		"male.txt":   {"ahmet", "ayşe", "mehmet", "can"},
+		"female.txt": {"zeynep", "refused"},
+	}
+	for file, expected := range tcs {
+		got := lines(t, filepath.Join(dir, file))
+		slices.Sort(got)
+		slices.Sort(expected)
+		if !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", file, expected, got)
+		}
+	}
+	if got := firstColumn(lines(t, filepath.Join(dir, "failed.txt"))); slices.Contains(got, "refused") {
+		t.Errorf("expected the overridden name not to be asked, got failed %v", got)
+	}
+
+	t.Run("prefilter", func(t *testing.T) {
+		setup(t)
+		// xkcd has no vowel, but the override wins over the prefilter
+		if err := os.WriteFile("labels/overrides.tsv", []byte("name\tgender\tnote\tauthor\nxkcd\tmale\ta handle\ttester\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		args := testArgs()
+		args.Overrides = "labels/overrides.tsv"
+		args.Prefilter = true
+		if err := label(args); err != nil {
+			t.Fatalf("label: %v", err)
+		}
+		dir := runDir(t)
+		if got := lines(t, filepath.Join(dir, "male.txt")); !slices.Contains(got, "xkcd") {
+			t.Errorf("expected the overridden xkcd in male.txt, got %v", got)
+		}
+		if got := lines(t, filepath.Join(dir, "unknown.txt")); slices.Contains(got, "xkcd") {
+			t.Errorf("expected the overridden xkcd not to be prefiltered, got unknown %v", got)
+		}
+		j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer j.Close()
+		overridden := 0
+		for b := range 4 {
+			e, _ := j.Done(b)
+			overridden += e.Overridden
+		}
+		if e, _ := j.Done(-1); e.Prefiltered != 0 || overridden != 1 {
+			t.Errorf("expected xkcd overridden and not prefiltered, got %d prefiltered, %d overridden", e.Prefiltered, overridden)
+		}
+	})
+}
+
+func TestLabel_outOfRange(t *testing.T) {
+	setup(t)
+	args := testArgs()
+	args.Shard = "0/2"
+	args.End = 1000
+	if err := label(args); err != nil {
+		t.Fatalf("expected the end to be clamped, got %v", err)
+	}
+	if got := len(lines(t, filepath.Join(runDir(t), "answers.jsonl"))); got == 0 {
+		t.Errorf("expected the names of the shard to be labeled")
+	}
+
+	args = testArgs()
+	args.Start = 1000
+	if err := label(args); err == nil {
+		t.Errorf("expected an error for the start out of range")
+	}
+	if dirs, _ := filepath.Glob("labels/[0-9]*"); len(dirs) != 1 {
+		t.Errorf("expected no run directory for the start out of range, got %v", dirs)
+	}
+
+	args = testArgs()
+	args.Batch = 0
+	if err := label(args); err == nil {
+		t.Errorf("expected an error for the empty batch")
+	}
+	args = testArgs()
+	args.Workers = 0
+	if err := label(args); err == nil {
+		t.Errorf("expected an error without a worker")
+	}
+	if dirs, _ := filepath.Glob("labels/[0-9]*"); len(dirs) != 1 {
+		t.Errorf("expected no run directory for the invalid arguments, got %v", dirs)
+	}
+}
+
+func TestJournal_tornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	if err := os.WriteFile(path, []byte(`{"batch":0,"included":3,"excluded":0}`+"\n"+`{"batch":1,"inc`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j.Done(1); ok {
+		t.Errorf("expected the torn batch 1 to be labeled again")
+	}
+	if err := j.Commit(JournalEntry{Batch: 1, Included: 2}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	for batch := range 2 {
+		if _, ok := j.Done(batch); !ok {
+			t.Errorf("expected the batch %d to be journaled after reopening", batch)
+		}
+	}
+}
+
+func TestCache_tornLine(t *testing.T) {
+	dir := t.TempDir()
+	c, err := OpenCache(dir, "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("m", []LabeledName{{Name: "ahmet", Gender: "male", Confidence: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// a run killed while writing the answer of ayşe
+	path := filepath.Join(dir, promptHash("prompt")+".jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"model":"m","key":"ay`)
+	f.Close()
+
+	c, err = OpenCache(dir, "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put("m", []LabeledName{{Name: "ayşe", Gender: "female", Confidence: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = OpenCache(dir, "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if cached, rest := c.Split("m", []string{"ahmet", "ayşe"}); len(cached) != 2 {
+		t.Errorf("expected both answers cached after reopening, missing %v", rest)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{Attempts: 100, Base: time.Second, Max: time.Minute}
+	for _, attempt := range []int{0, 1, 5, 34, 63, 64, 1000} {
+		d := p.backoff(attempt)
+		if d < 0 || d > p.Max {
+			t.Errorf("attempt %d: expected the backoff within %v, got %v", attempt, p.Max, d)
+		}
+	}
+	if d := p.backoff(1000); d < p.Max/2 {
+		t.Errorf("expected the backoff of a late attempt at the max, got %v", d)
+	}
+	if d := p.backoff(1); d < time.Second || d > 2*time.Second {
+		t.Errorf("expected the backoff of the second attempt between 1s and 2s, got %v", d)
+	}
+}
+
+func TestOpenAICompatible(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		text      string
+		transient bool
+		fatal     bool
+	}{
+		{"success", http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`, "hello", false, false},
+		{"rate limited", http.StatusTooManyRequests, `{"error":"slow down"}`, "", true, false},
+		{"unavailable", http.StatusServiceUnavailable, `{"error":"loading"}`, "", true, false},
+		{"unauthorized", http.StatusUnauthorized, `{"error":"bad key"}`, "", false, true},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got chatRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+					t.Errorf("unexpected request to %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			g := genkit.Init(ctx)
+			oc := &OpenAICompatible{BaseURL: srv.URL + "/v1/", APIKey: "key"}
+			m := oc.DefineModel(g, "stub")
+			res, err := genkit.Generate(ctx, g, ai.WithModel(m), ai.WithSystem("be brief"), ai.WithPrompt("hi"))
+
+			if got.Model != "stub" || len(got.Messages) != 2 || got.Messages[0].Role != "system" || got.Messages[1].Content != "hi" {
+				t.Errorf("unexpected request body %+v", got)
+			}
+			if tt.text != "" {
+				if err != nil {
+					t.Fatalf("generate: %v", err)
+				}
+				if res.Text() != tt.text || res.FinishReason != ai.FinishReasonStop || res.Usage.TotalTokens != 3 {
+					t.Errorf("unexpected response %+v", res)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error, got %+v", res)
+			}
+			if transient(err) != tt.transient || fatal(err) != tt.fatal {
+				t.Errorf("expected transient %v and fatal %v, got %v and %v for %v", tt.transient, tt.fatal, transient(err), fatal(err), err)
+			}
+		})
+	}
+}
+
+func TestPrefilter(t *testing.T) {
+	tests := []struct {
+		name, expected string
+	}{
+		{"ayşe", ""},
+		{"lynn", ""},
+		{"bryn", ""},
+		{"josé", ""},
+		{"zoë", ""},
+		{"søren", ""},
+		{"ayşe-nur", ""},
+		{"nmbrs", "no vowels"},
+		{"xkcd", "no vowels"},
+		{"admin", "organization"},
+		{"ahmet123", "digits"},
+		{"a.", "initial"},
+		{"ahmet.yilmaz", "handle"},
+	}
+	for _, tt := range tests {
+		if got := prefilter(tt.name); got != tt.expected {
+			t.Errorf("prefilter(%q) = %q, expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// a server that can't be reached stops the run instead of quarantining
+// every name, so a resumed run asks them again
+func TestLabel_unreachable(t *testing.T) {
+	setup(t)
+	args := testArgs()
+	args.Model = "openai/x"
+	args.OpenAIURL = "http://127.0.0.1:1/v1"
+	args.Retries = 2
+	if err := label(args); err == nil {
+		t.Fatal("expected the run to stop")
+	}
+	dir := runDir(t)
+	if got := lines(t, filepath.Join(dir, "failed.txt")); len(got) > 0 {
+		t.Errorf("expected no quarantined name, got %v", got)
+	}
+	j, err := OpenJournal(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if _, ok := j.Done(0); ok {
+		t.Errorf("expected the batch 0 left to be asked again")
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{fmt.Errorf("do: %w", syscall.ECONNREFUSED), true},
+		{fmt.Errorf("do: %w", syscall.ECONNRESET), true},
+		{fmt.Errorf("decoding response: %w", io.ErrUnexpectedEOF), true},
+		{&url.Error{Op: "Post", URL: "http://x", Err: errors.New("EOF")}, true},
+		{&url.Error{Op: "Post", URL: "http://x", Err: context.Canceled}, false},
+		{errors.New("no choices in the response"), false},
+	}
+	for _, tt := range tests {
+		if got := transient(tt.err); got != tt.expected {
+			t.Errorf("transient(%v) = %v, expected %v", tt.err, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Builds the list of unique given names and their frequencies per
+// community from the member lists under data/.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"main/internal/members"
+)
+
+type Args struct {
+	Data, Names, Frequencies string
+	GivenNames               string
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.Data, "data", "data", "directory of the member lists, a file per community")
+	flag.StringVar(&args.Names, "names", "labels/uniq-names.txt", "output for the unique names")
+	flag.StringVar(&args.Frequencies, "frequencies", "labels/name-frequencies.tsv", "output for the name counts per community")
+	flag.StringVar(&args.GivenNames, "given-names", "labels/male.txt,labels/female.txt", "comma separated lists of the known given names, for joining the compounds like \"ayşe nur\" (empty to take only the first word)")
+	flag.Parse()
+
+	known := members.GivenNames{}
+	if args.GivenNames != "" {
+		var err error
+		known, err = members.ReadGivenNames(strings.Split(args.GivenNames, ",")...)
+		if err != nil {
+			return fmt.Errorf("reading known given names: %w", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(args.Data, "*"))
+	if err != nil {
+		return fmt.Errorf("glob: %w", err)
+	}
+
+	f := members.NewFrequencies()
+	for _, file := range files {
+		if fi, err := os.Stat(file); err != nil || fi.IsDir() {
+			continue
+		}
+		fullnames, err := members.ReadCommunity(file)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", file, err)
+		}
+		names := []string{}
+		for _, n := range fullnames {
+			names = append(names, known.Compound(n))
+		}
+		f.Add(members.Community(file), names)
+		fmt.Printf("%s: %d members\n", members.Community(file), len(names))
+	}
+
+	if err := f.Write(args.Frequencies); err != nil {
+		return fmt.Errorf("writing frequencies: %w", err)
+	}
+
+	out, err := os.Create(args.Names)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer out.Close()
+	// the first words of the compounds are listed too, for the outputs
+	// keyed by the first word as the member lists of the README snippet
+	uniq := []string{}
+	for _, name := range f.Names() {
+		uniq = append(uniq, name)
+		if first, _, ok := strings.Cut(name, " "); ok {
+			uniq = append(uniq, first)
+		}
+	}
+	slices.Sort(uniq)
+	uniq = slices.Compact(uniq)
+
+	w := bufio.NewWriter(out)
+	for _, name := range uniq {
+		fmt.Fprintln(w, name)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing names: %w", err)
+	}
+
+	fmt.Printf("%d unique names in %d communities, %d with the first words of the compounds\n", len(f.Counts), len(f.Communities), len(uniq))
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// JournalEntry is appended once all names of a batch are written into
+// the output files. The names labeled without asking are journaled as
+// the batch -1, and the names taken from the cache as the batch -2.
+type JournalEntry struct {
+	Batch       int `json:"batch"`
+	Included    int `json:"included"`
+	Excluded    int `json:"excluded"`
+	Failed      int `json:"failed,omitempty"`
+	Demoted     int `json:"demoted,omitempty"`     // below the min confidence
+	Disagreed   int `json:"disagreed,omitempty"`   // by the models of the ensemble
+	Cached      int `json:"cached,omitempty"`      // answers not asked again
+	Prefiltered int `json:"prefiltered,omitempty"` // only in the batch -1
+	Overridden  int `json:"overridden,omitempty"`  // by the labels fixed by hand
+	ReconcileStats
+	Names []string `json:"names,omitempty"` // only in the batches -1 and -2
+}
+
+// Journal is the checkpoint file kept next to the output files of a run.
+// A resumed run skips the batches already listed in it.
+type Journal struct {
+	f    *os.File
+	done map[int]JournalEntry
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{done: map[int]JournalEntry{}}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	err = readLines(f, func(line []byte) {
+		e := JournalEntry{}
+		if err := json.Unmarshal(line, &e); err != nil {
+			return
+		}
+		j.done[e.Batch] = e
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	j.f = f
+	return j, nil
+}
+
+// readLines reads the lines of the file opened for appending. The last
+// line might be cut short if the previous run was killed while writing
+// it; it is cut off so the next line written starts on a line of its own,
+// and what it held is simply done again.
+func readLines(f *os.File, read func(line []byte)) error {
+	r := bufio.NewReader(f)
+	size := int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				if err := f.Truncate(size); err != nil {
+					return fmt.Errorf("truncate: %w", err)
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		size += int64(len(line))
+		read(line)
+	}
+}
+
+func (j *Journal) Done(batch int) (JournalEntry, bool) {
+	e, ok := j.done[batch]
+	return e, ok
+}
+
+func (j *Journal) Len() int {
+	return len(j.done)
+}
+
+// Commit should be called only after the output files of the batch are
+// synced, otherwise a crash can leave a journaled batch without its names.
+func (j *Journal) Commit(e JournalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("sync: %w", err)
+	}
+	j.done[e.Batch] = e
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+// the arguments that decide the batch boundaries are saved into the run
+// directory, so a resumed run can't be started with different ones
+func saveArgs(path string, args Args) error {
+	b, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func loadArgs(path string) (Args, error) {
+	args := Args{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return args, fmt.Errorf("read: %w", err)
+	}
+	if err := json.Unmarshal(b, &args); err != nil {
+		return args, fmt.Errorf("unmarshal: %w", err)
+	}
+	return args, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// spending is a request made in the last minute. Tokens starts as an
+// estimation and gets corrected when the usage is reported by the model.
+type spending struct {
+	at     time.Time
+	tokens int
+}
+
+// Limiter is shared by the workers to stay under the requests-per-minute
+// and tokens-per-minute quotas. Zero values mean unlimited.
+type Limiter struct {
+	rpm, tpm int
+
+	mu     sync.Mutex
+	window []*spending
+}
+
+func NewLimiter(rpm, tpm int) *Limiter {
+	return &Limiter{rpm: rpm, tpm: tpm}
+}
+
+// drops the spendings older than a minute, expects the lock held
+func (l *Limiter) prune(now time.Time) {
+	i := 0
+	for i < len(l.window) && now.Sub(l.window[i].at) >= time.Minute {
+		i++
+	}
+	l.window = l.window[i:]
+}
+
+func (l *Limiter) tokens() int {
+	total := 0
+	for _, s := range l.window {
+		total += s.tokens
+	}
+	return total
+}
+
+// Wait blocks until a request of estimated size fits into both quotas. An
+// estimation bigger than the whole TPM quota is let through once the
+// window is empty, otherwise it would wait forever.
+func (l *Limiter) Wait(ctx context.Context, estimated int) (*spending, error) {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.prune(now)
+		var (
+			rpmOk = l.rpm <= 0 || len(l.window) < l.rpm
+			tpmOk = l.tpm <= 0 || len(l.window) == 0 || l.tokens()+estimated <= l.tpm
+		)
+		if rpmOk && tpmOk {
+			s := &spending{at: now, tokens: estimated}
+			l.window = append(l.window, s)
+			l.mu.Unlock()
+			return s, nil
+		}
+		wait := l.window[0].at.Add(time.Minute).Sub(now)
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Settle replaces the estimation with the actual usage
+func (l *Limiter) Settle(s *spending, actual int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s.tokens = actual
+}
+
+// a rough count for the prompt (4 characters per token) plus the JSON
+// object written for each name in the answer
+func estimateTokens(prompt string, names int) int {
+	return len(prompt)/4 + names*20
+}
@@ -0,0 +1,199 @@
+// Combines the labels of several runs, such as the shards of a labeling,
+// into the canonical label files under labels/.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"main/internal/labels"
+)
+
+type Args struct {
+	Out       string
+	Latest    bool
+	Overrides string
+}
+
+// Conflict is a name given different definite labels (male, female or
+// unisex) by the runs
+type Conflict struct {
+	Name   string
+	Labels map[string]string // by run directory
+}
+
+// Run is the labels of a run directory
+type Run struct {
+	Dir    string
+	Labels labels.Labels
+	Soft   labels.Soft
+}
+
+// Merged is the labels of the runs combined
+type Merged struct {
+	Labels     labels.Labels
+	Soft       labels.Soft
+	Conflicts  []Conflict
+	Overridden int // labels changed by the overrides
+}
+
+// merge combines the runs in their order. A definite label wins over
+// unknown, as when the unknown names of a run are labeled again; names
+// with different definite labels are conflicts, left out unless latest,
+// when the last run wins. The overrides are applied after merging.
+func merge(runs []Run, latest bool, o labels.Overrides) Merged {
+	merged := labels.Labels{}
+	from := map[string]map[string]string{} // labels of each name by run
+	sums, counts := labels.Soft{}, map[string]int{}
+	for _, r := range runs {
+		for name, p := range r.Soft {
+			sums[name] += p
+			counts[name]++
+		}
+		for name, gender := range r.Labels {
+			if from[name] == nil {
+				from[name] = map[string]string{}
+			}
+			from[name][r.Dir] = gender
+			if gender != "unknown" || merged[name] == "" {
+				merged[name] = gender
+			}
+		}
+	}
+
+	conflicts := []Conflict{}
+	for _, name := range merged.Names() {
+		genders := []string{}
+		for _, gender := range from[name] {
+			if gender != "unknown" {
+				genders = append(genders, gender)
+			}
+		}
+		slices.Sort(genders)
+		if len(slices.Compact(genders)) > 1 {
+			conflicts = append(conflicts, Conflict{Name: name, Labels: from[name]})
+			if !latest {
+				delete(merged, name)
+			}
+		}
+	}
+
+	// the soft labels of the runs are averaged, conflicting or not
+	soft := labels.Soft{}
+	for name, sum := range sums {
+		soft[name] = sum / float64(counts[name])
+	}
+
+	overridden := merged.Apply(o)
+	soft.Apply(o)
+	return Merged{Labels: merged, Soft: soft, Conflicts: conflicts, Overridden: overridden}
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.Out, "out", "labels", "directory to write the merged label files into")
+	flag.BoolVar(&args.Latest, "latest", false, "resolve conflicts by the run given last instead of leaving the name out")
+	flag.StringVar(&args.Overrides, "overrides", "labels/overrides.tsv", "labels fixed by hand, applied over the runs (empty to skip)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: merge [flags] <run dir>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		flag.Usage()
+		return fmt.Errorf("no run directories given")
+	}
+
+	runs := []Run{}
+	for _, dir := range dirs {
+		soft, err := labels.ReadSoftIfExists(filepath.Join(dir, "soft.tsv"))
+		if err != nil {
+			return fmt.Errorf("reading soft labels of %s: %w", dir, err)
+		}
+		l, err := labels.Read(dir)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", dir, err)
+		}
+		if len(l) == 0 {
+			fmt.Printf("WARNING: no labels in %s\n", dir)
+		}
+		runs = append(runs, Run{Dir: dir, Labels: l, Soft: soft})
+		fmt.Printf("%s: %d names\n", dir, len(l))
+	}
+
+	var o labels.Overrides
+	if args.Overrides != "" {
+		var err error
+		if o, err = labels.ReadOverrides(args.Overrides); err != nil {
+			return fmt.Errorf("reading overrides: %w", err)
+		}
+	}
+	m := merge(runs, args.Latest, o)
+
+	if err := os.MkdirAll(args.Out, 0755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	if err := m.Labels.Write(args.Out); err != nil {
+		return fmt.Errorf("writing labels: %w", err)
+	}
+	if err := m.Soft.Write(filepath.Join(args.Out, "soft.tsv")); err != nil {
+		return fmt.Errorf("writing soft labels: %w", err)
+	}
+	if err := writeConflicts(filepath.Join(args.Out, "conflicts.tsv"), dirs, m.Conflicts); err != nil {
+		return fmt.Errorf("writing conflicts: %w", err)
+	}
+
+	for _, c := range m.Conflicts {
+		fmt.Printf("conflict: %s:", c.Name)
+		for _, dir := range dirs {
+			if gender, ok := c.Labels[dir]; ok {
+				fmt.Printf(" %s=%s", filepath.Base(dir), gender)
+			}
+		}
+		fmt.Println()
+	}
+	counts := map[string]int{}
+	for _, gender := range m.Labels {
+		counts[gender]++
+	}
+	fmt.Printf("%d names from %d runs: %d male, %d female, %d unisex, %d unknown, %d conflicts, %d changed by overrides\n",
+		len(m.Labels), len(dirs), counts["male"], counts["female"], counts["unisex"], counts["unknown"], len(m.Conflicts), m.Overridden)
+	return nil
+}
+
+// writeConflicts writes a tab separated table with a column of labels for
+// each run, left empty when the run didn't label the name
+func writeConflicts(path string, dirs []string, conflicts []Conflict) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "name\t%s\n", strings.Join(dirs, "\t"))
+	for _, c := range conflicts {
+		fmt.Fprint(w, c.Name)
+		for _, dir := range dirs {
+			fmt.Fprintf(w, "\t%s", c.Labels[dir])
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return f.Close()
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	"main/internal/labels"
+)
+
+func TestMerge_unknown(t *testing.T) {
+	// a definite label wins over unknown whichever run comes last
+	runs := []Run{
+		{Dir: "a", Labels: labels.Labels{"ahmet": "male", "deniz": "unknown", "umut": "unknown"}},
+		{Dir: "b", Labels: labels.Labels{"ahmet": "unknown", "deniz": "unisex", "umut": "unknown"}},
+	}
+	m := merge(runs, false, nil)
+	expected := labels.Labels{"ahmet": "male", "deniz": "unisex", "umut": "unknown"}
+	if !maps.Equal(m.Labels, expected) || len(m.Conflicts) != 0 {
+		t.Errorf("expected %v without conflicts, got %v and %v", expected, m.Labels, m.Conflicts)
+	}
+}
+
+func TestMerge_conflicts(t *testing.T) {
+	runs := []Run{
+		{Dir: "a", Labels: labels.Labels{"ahmet": "male", "deniz": "male", "zeynep": "female"}},
+		{Dir: "b", Labels: labels.Labels{"deniz": "unisex", "zeynep": "female"}},
+		{Dir: "c", Labels: labels.Labels{"deniz": "unknown"}},
+	}
+	m := merge(runs, false, nil)
+	expected := labels.Labels{"ahmet": "male", "zeynep": "female"}
+	if !maps.Equal(m.Labels, expected) {
+		t.Errorf("expected the conflict left out as %v, got %v", expected, m.Labels)
+	}
+	if len(m.Conflicts) != 1 || m.Conflicts[0].Name != "deniz" || m.Conflicts[0].Labels["c"] != "unknown" {
+		t.Errorf("expected deniz to conflict with the labels of every run, got %v", m.Conflicts)
+	}
+
+	// the last definite label wins with latest
+	m = merge(runs, true, nil)
+	if m.Labels["deniz"] != "unisex" || len(m.Conflicts) != 1 {
+		t.Errorf("expected deniz unisex by the run b, got %q with %v", m.Labels["deniz"], m.Conflicts)
+	}
+}
+
+func TestMerge_overrides(t *testing.T) {
+	runs := []Run{
+		{Dir: "a", Labels: labels.Labels{"deniz": "male", "ayşe": "male"}, Soft: labels.Soft{"deniz": 0.2, "ayşe": 0}},
+		{Dir: "b", Labels: labels.Labels{"deniz": "female"}, Soft: labels.Soft{"deniz": 0.6}},
+	}
+	o := labels.Overrides{
+		"deniz": {Gender: "unisex"},
+		"ayşe":  {Gender: "female"},
+	}
+	// the overrides apply after merging, so they settle the conflicts too
+	m := merge(runs, false, o)
+	expected := labels.Labels{"deniz": "unisex", "ayşe": "female"}
+	if !maps.Equal(m.Labels, expected) || m.Overridden != 2 {
+		t.Errorf("expected %v with 2 overridden, got %v with %d", expected, m.Labels, m.Overridden)
+	}
+	if m.Soft["deniz"] != 0.5 || m.Soft["ayşe"] != 1 {
+		t.Errorf("expected the soft labels of the overrides, got %v", m.Soft)
+	}
+	if len(m.Conflicts) != 1 {
+		t.Errorf("expected the conflict on deniz to be reported, got %v", m.Conflicts)
+	}
+}
@@ -0,0 +1,82 @@
+// Prints the canonical form of each name in the input files, or the
+// standard input, one name per line.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"main/internal/normalize"
+)
+
+type Args struct {
+	Uniq bool
+}
+
+func names(r io.Reader, each func(string)) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// the source lines of the member lists under data/
+		if strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		if n := normalize.Name(s.Text()); n != "" {
+			each(n)
+		}
+	}
+	return s.Err()
+}
+
+func Main() error {
+	args := Args{}
+	flag.BoolVar(&args.Uniq, "uniq", false, "sort the names and print each only once")
+	flag.Parse()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	seen := map[string]bool{}
+	each := func(n string) {
+		if args.Uniq {
+			seen[n] = true
+		} else {
+			fmt.Fprintln(w, n)
+		}
+	}
+
+	if flag.NArg() == 0 {
+		if err := names(os.Stdin, each); err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+	}
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open: %w", err)
+		}
+		err = names(f, each)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	if args.Uniq {
+		for _, n := range slices.Sorted(maps.Keys(seen)) {
+			fmt.Fprintln(w, n)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
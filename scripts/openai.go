@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// the models under this provider are sent to [OpenAICompatible]
+const openaiProvider = "openai"
+
+// OpenAICompatible serves the models from an OpenAI-compatible chat
+// completions endpoint, such as llama.cpp, vLLM or a local stub.
+type OpenAICompatible struct {
+	BaseURL string // eg. http://localhost:8080/v1
+	APIKey  string // optional for local servers
+	Client  *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+var chatRoles = map[ai.Role]string{
+	ai.RoleSystem: "system",
+	ai.RoleUser:   "user",
+	ai.RoleModel:  "assistant",
+}
+
+var finishReasons = map[string]ai.FinishReason{
+	"stop":           ai.FinishReasonStop,
+	"length":         ai.FinishReasonLength,
+	"content_filter": ai.FinishReasonBlocked,
+}
+
+// the status is picked to let [transient] and [fatal] classify the error
+// as they would for the Gemini API
+func httpStatus(code int) core.StatusName {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return core.RESOURCE_EXHAUSTED
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return core.DEADLINE_EXCEEDED
+	case code >= 500:
+		return core.UNAVAILABLE
+	case code == http.StatusUnauthorized:
+		return core.UNAUTHENTICATED
+	case code == http.StatusForbidden:
+		return core.PERMISSION_DENIED
+	case code == http.StatusNotFound:
+		return core.NOT_FOUND
+	default:
+		return core.INVALID_ARGUMENT
+	}
+}
+
+func (oc *OpenAICompatible) complete(ctx context.Context, body chatRequest) (*chatResponse, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(oc.BaseURL, "/")+"/chat/completions", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if oc.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+oc.APIKey)
+	}
+
+	client := oc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, core.NewError(httpStatus(res.StatusCode), "%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	cr := &chatResponse{}
+	if err := json.NewDecoder(res.Body).Decode(cr); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if len(cr.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in the response")
+	}
+	return cr, nil
+}
+
+// DefineModel registers the model as "openai/<model>". The structured
+// output is left to genkit, which adds the schema to the prompt and
+// parses the JSON out of the text.
+func (oc *OpenAICompatible) DefineModel(g *genkit.Genkit, model string) ai.Model {
+	opts := &ai.ModelOptions{
+		Label: model,
+		Supports: &ai.ModelSupports{
+			Multiturn:   true,
+			SystemRole:  true,
+			Constrained: ai.ConstrainedSupportNone,
+		},
+	}
+	return genkit.DefineModel(g, openaiProvider+"/"+model, opts,
+		func(ctx context.Context, mr *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			body := chatRequest{Model: model}
+			for _, m := range mr.Messages {
+				role, ok := chatRoles[m.Role]
+				if !ok {
+					continue
+				}
+				text := strings.Builder{}
+				for _, p := range m.Content {
+					if p.IsText() {
+						text.WriteString(p.Text)
+					}
+				}
+				body.Messages = append(body.Messages, chatMessage{Role: role, Content: text.String()})
+			}
+
+			cr, err := oc.complete(ctx, body)
+			if err != nil {
+				return nil, err
+			}
+
+			choice := cr.Choices[0]
+			reason, ok := finishReasons[choice.FinishReason]
+			if !ok {
+				reason = ai.FinishReasonOther
+			}
+			return &ai.ModelResponse{
+				Request:      mr,
+				Message:      ai.NewModelTextMessage(choice.Message.Content),
+				FinishReason: reason,
+				Usage: &ai.GenerationUsage{
+					InputTokens:  cr.Usage.PromptTokens,
+					OutputTokens: cr.Usage.CompletionTokens,
+					TotalTokens:  cr.Usage.TotalTokens,
+				},
+			}, nil
+		},
+	)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// words that show up as the first token of the accounts of companies,
+// communities and bots instead of people
+var organizationWords = map[string]bool{
+	"admin": true, "info": true, "team": true, "official": true,
+	"inc": true, "ltd": true, "llc": true, "şti": true,
+	"tech": true, "software": true, "yazılım": true, "bilişim": true,
+	"teknoloji": true, "technology": true, "digital": true, "dijital": true,
+	"academy": true, "akademi": true, "bootcamp": true, "kulübü": true,
+	"club": true, "community": true, "topluluk": true, "group": true,
+	"hr": true, "ik": true, "recruiter": true, "kariyer": true,
+}
+
+// the vowels of Turkish and the other Latin alphabets, with y as in Lynn
+// and Bryn, in the composed form of the canonical names
+const vowels = "aeıioöuüâîûy" + "àáãäåæèéêëìíïòóôõøœùúýÿ"
+
+// prefilter returns why the token can't be a given name, or an empty
+// string for the plausible names that are worth asking the model. It
+// expects the canonical form of the name.
+func prefilter(name string) string {
+	if organizationWords[strings.TrimRight(name, ".")] {
+		return "organization"
+	}
+	if strings.Contains(name, "@") {
+		return "email"
+	}
+
+	letters, latin := 0, true
+	for _, r := range name {
+		if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+			latin = false
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.Is(unicode.Mn, r):
+			letters++
+		case unicode.IsDigit(r):
+			return "digits"
+		case unicode.In(r, unicode.So, unicode.Sk, unicode.Sm):
+			return "emoji or symbol"
+		case r == '\'' || r == '’' || r == '-' || r == ' ':
+			// Ayşe-Nur, O'Brien
+		case r == '.':
+			// initials are checked below
+		default:
+			return "handle"
+		}
+	}
+
+	switch {
+	case letters == 0:
+		return "no letters"
+	case letters == 1:
+		return "initial"
+	case strings.Contains(strings.TrimSuffix(name, "."), "."):
+		// a.b, ahmet.yilmaz
+		if strings.Count(name, ".") >= letters/2 {
+			return "initial"
+		}
+		return "handle"
+	case strings.HasSuffix(name, ".") && letters <= 2:
+		return "initial"
+	case latin && !strings.ContainsAny(name, vowels):
+		return "no vowels"
+	}
+	return ""
+}
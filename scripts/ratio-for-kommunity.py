@@ -1,4 +1,5 @@
 import argparse
+import unicodedata
 
 parser = argparse.ArgumentParser(description="Example of flag parsing")
 parser.add_argument(
@@ -9,14 +10,48 @@ parser.add_argument(
 )
 args = parser.parse_args()
 
+
+def canonical(name):
+    """Same as Name in internal/normalize, kept in sync by its tests"""
+    name = "".join(c for c in name if unicodedata.category(c) != "Cf")
+    name = unicodedata.normalize("NFC", name)
+    name = name.replace("I", "ı").replace("İ", "i").lower()
+    name = name.replace("i\u0307", "i")
+    name = unicodedata.normalize("NFC", name)
+    return " ".join(name.split())
+
+
 with open(args.input, "r") as file:
-    names = [name.strip().lower() for name in file if name]
+    # the "# source:" and "# captured:" lines of the extract command
+    names = [
+        canonical(name)
+        for name in file
+        if canonical(name) and not name.startswith("#")
+    ]
 
 with open("labels/male.txt", "r") as file:
-    labels_male = {name.strip().lower() for name in file if name}
+    labels_male = {canonical(name) for name in file if name}
 
 with open("labels/female.txt", "r") as file:
-    labels_female = {name.strip().lower() for name in file if name}
+    labels_female = {canonical(name) for name in file if name}
+
+# labels fixed by hand win over the labels of the model
+try:
+    with open("labels/overrides.tsv", "r") as file:
+        next(file)  # header
+        for line in file:
+            if not line.strip() or line.startswith("#"):
+                continue
+            name, gender = line.rstrip("\n").split("\t")[:2]
+            name = canonical(name)
+            labels_male.discard(name)
+            labels_female.discard(name)
+            if gender == "male":
+                labels_male.add(name)
+            elif gender == "female":
+                labels_female.add(name)
+except FileNotFoundError:
+    pass
 
 import sys
 
@@ -24,7 +59,14 @@ count_males = 0
 count_females = 0
 count_excluded = 0
 
-for name in names:
+def labeled(name):
+    """Compounds without a label of their own fall back to the first word"""
+    if name in labels_female or name in labels_male:
+        return name
+    return name.split(" ")[0]
+
+
+for name in map(labeled, names):
     if name in labels_female:
         count_females += 1
     elif name in labels_male:
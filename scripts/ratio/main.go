@@ -0,0 +1,152 @@
+// Prints the M:F ratio of each community with its confidence interval,
+// counting the members by the labels of their given names.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"main/internal/labels"
+	"main/internal/members"
+	"main/internal/ratio"
+)
+
+type Args struct {
+	Male, Female string
+	Overrides    string
+	Level        float64
+	JSON         bool
+	Bootstrap    int
+	Seed         uint64
+	Sensitivity  bool
+	ExcludedRate float64
+	Soft         string
+}
+
+func printResult(r ratio.Result) {
+	fmt.Printf("%s: %d members, %d male, %d female, %d excluded\n", r.Community, r.Members, r.Male, r.Female, r.Excluded)
+	fmt.Printf("  female share: %s (%g%% CI %s – %s)\n", ratio.FormatShare(r.FemaleShare.Value),
+		100*r.Level, ratio.FormatShare(r.FemaleShare.Low), ratio.FormatShare(r.FemaleShare.High))
+	fmt.Printf("  M:F         : %s (%g%% CI %s – %s)\n", ratio.FormatRatio(r.Ratio.Value),
+		100*r.Level, ratio.FormatRatio(r.Ratio.Low), ratio.FormatRatio(r.Ratio.High))
+	if b := r.Bootstrapped; b != nil {
+		fmt.Printf("  bootstrap   : %d resamples, seed %d\n", b.Iterations, b.Seed)
+		fmt.Printf("  female share: %s (%g%% percentiles %s – %s)\n", ratio.FormatShare(b.FemaleShare.Value),
+			100*r.Level, ratio.FormatShare(b.FemaleShare.Low), ratio.FormatShare(b.FemaleShare.High))
+		fmt.Printf("  M:F         : %s (%g%% percentiles %s – %s)\n", ratio.FormatRatio(b.Ratio.Value),
+			100*r.Level, ratio.FormatRatio(b.Ratio.Low), ratio.FormatRatio(b.Ratio.High))
+	}
+	if e := r.Expected; e != nil {
+		fmt.Printf("  soft labels : %d members counted, %d not\n", e.Counted, e.Uncounted)
+		fmt.Printf("  expected    : %.1f male, %.1f female, variance %.1f\n", e.Male, e.Female, e.Variance)
+		fmt.Printf("  female share: %s (%g%% CI %s – %s)\n", ratio.FormatShare(e.FemaleShare.Value),
+			100*r.Level, ratio.FormatShare(e.FemaleShare.Low), ratio.FormatShare(e.FemaleShare.High))
+		fmt.Printf("  M:F         : %s (%g%% CI %s – %s)\n", ratio.FormatRatio(e.Ratio.Value),
+			100*r.Level, ratio.FormatRatio(e.Ratio.Low), ratio.FormatRatio(e.Ratio.High))
+	}
+	if s := r.Bounded; s != nil {
+		fmt.Printf("  sensitivity to the %d excluded:\n", r.Excluded)
+		for _, sc := range s.Scenarios {
+			fmt.Printf("    %-13s (%6s female): female share %s, M:F %s\n", sc.Name, ratio.FormatShare(sc.Rate), ratio.FormatShare(sc.FemaleShare), ratio.FormatRatio(sc.Ratio))
+		}
+		fmt.Printf("  bounds      : M:F %s (%s – %s), female share %s (%s – %s)\n",
+			ratio.FormatRatio(s.Ratio.Value), ratio.FormatRatio(s.Ratio.Low), ratio.FormatRatio(s.Ratio.High),
+			ratio.FormatShare(s.FemaleShare.Value), ratio.FormatShare(s.FemaleShare.Low), ratio.FormatShare(s.FemaleShare.High))
+	}
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.Male, "male", "labels/male.txt", "names labeled male")
+	flag.StringVar(&args.Female, "female", "labels/female.txt", "names labeled female")
+	flag.StringVar(&args.Overrides, "overrides", "labels/overrides.tsv", "labels fixed by hand, applied over the labels (empty to skip)")
+	flag.Float64Var(&args.Level, "level", 0.95, "confidence level of the intervals")
+	flag.IntVar(&args.Bootstrap, "bootstrap", 0, "resamples of the member lists for the percentile intervals (0 to skip)")
+	flag.Uint64Var(&args.Seed, "seed", 1, "seed of the resampling, the same seed gives the same intervals")
+	flag.BoolVar(&args.Sensitivity, "sensitivity", false, "recompute the ratios with the excluded members counted as all male, all female and at the observed rate")
+	flag.Float64Var(&args.ExcludedRate, "excluded-female-rate", -1, "with --sensitivity, also count the excluded members as female at this rate between 0 and 1")
+	flag.StringVar(&args.Soft, "soft", "", "soft labels of the merge command, eg. labels/soft.tsv, for the expected counts by the probabilities of the names being female")
+	flag.BoolVar(&args.JSON, "json", false, "print the results as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: ratio [flags] <member list>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return fmt.Errorf("no member lists given")
+	}
+	if args.Level <= 0 || args.Level >= 1 {
+		return fmt.Errorf("expected the level between 0 and 1: %g", args.Level)
+	}
+	if args.ExcludedRate != -1 && (args.ExcludedRate < 0 || args.ExcludedRate > 1) {
+		return fmt.Errorf("expected the excluded female rate between 0 and 1: %g", args.ExcludedRate)
+	}
+
+	l, err := labels.ReadFiles(args.Male, args.Female)
+	if err != nil {
+		return fmt.Errorf("reading labels: %w", err)
+	}
+	if args.Overrides != "" {
+		o, err := labels.ReadOverrides(args.Overrides)
+		if err != nil {
+			return fmt.Errorf("reading overrides: %w", err)
+		}
+		l.Apply(o)
+	}
+
+	var soft labels.Soft
+	if args.Soft != "" {
+		if soft, err = labels.ReadSoft(args.Soft); err != nil {
+			return fmt.Errorf("reading soft labels: %w", err)
+		}
+		if args.Overrides != "" {
+			o, err := labels.ReadOverrides(args.Overrides)
+			if err != nil {
+				return fmt.Errorf("reading overrides: %w", err)
+			}
+			soft.Apply(o)
+		}
+	}
+
+	results := []ratio.Result{}
+	for _, path := range flag.Args() {
+		names, err := ratio.ReadCommunity(path, l)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		r := ratio.Estimate(members.Community(path), names, l, args.Level)
+		if args.Bootstrap > 0 {
+			r.Bootstrap(args.Bootstrap, args.Seed)
+		}
+		if soft != nil {
+			r.Soft(names, soft)
+		}
+		if args.Sensitivity {
+			r.Sensitivity(args.ExcludedRate)
+		}
+		results = append(results, r)
+	}
+
+	if args.JSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(results); err != nil {
+			return fmt.Errorf("encoding: %w", err)
+		}
+		return nil
+	}
+	for _, r := range results {
+		printResult(r)
+	}
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
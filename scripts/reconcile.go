@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"main/internal/normalize"
+)
+
+// the rounds of asking again for the names the model skipped before
+// quarantining them
+const requeueRounds = 2
+
+var errMissing = errors.New("missing from the answers")
+
+// ReconcileStats counts how the answers differed from the question
+type ReconcileStats struct {
+	Renamed    int `json:"renamed,omitempty"`    // matched to an input name by the loose form
+	Duplicated int `json:"duplicated,omitempty"` // answered more than once, first one is kept
+	Rejected   int `json:"rejected,omitempty"`   // not in the batch
+	Requeued   int `json:"requeued,omitempty"`   // skipped by the model and asked again
+}
+
+func (s *ReconcileStats) Add(o ReconcileStats) {
+	s.Renamed += o.Renamed
+	s.Duplicated += o.Duplicated
+	s.Rejected += o.Rejected
+	s.Requeued += o.Requeued
+}
+
+// looseKey is used only to match the answers of a batch back to its names,
+// so it can be more aggressive than the canonical form: diacritics and the
+// dotless i are ignored too, as models often lose them.
+func looseKey(name string) string {
+	b := strings.Builder{}
+	for _, r := range norm.NFD.String(normalize.Name(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r == 'ı':
+			b.WriteRune('i')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// reconcile maps the answers to the names of the question. Returned items
+// carry the input names in the input order. Names without an answer are
+// returned separately.
+func reconcile(names []string, items []LabeledName) ([]LabeledName, []string, ReconcileStats) {
+	stats := ReconcileStats{}
+
+	exact := map[string]int{}
+	loose := map[string][]int{}
+	for i, name := range names {
+		exact[name] = i
+		k := looseKey(name)
+		loose[k] = append(loose[k], i)
+	}
+
+	got := make([]*LabeledName, len(names))
+	for _, item := range items {
+		i, ok := exact[item.Name]
+		if !ok {
+			for _, c := range loose[looseKey(item.Name)] {
+				if got[c] == nil {
+					i, ok = c, true
+					stats.Renamed++
+					break
+				}
+			}
+		}
+		if !ok {
+			if len(loose[looseKey(item.Name)]) > 0 {
+				stats.Duplicated++
+			} else {
+				fmt.Printf("WARNING: rejected answer for a name not in the batch: %q\n", item.Name)
+				stats.Rejected++
+			}
+			continue
+		}
+		if got[i] != nil {
+			stats.Duplicated++
+			continue
+		}
+		item.Name = names[i]
+		got[i] = &item
+	}
+
+	matched := []LabeledName{}
+	missing := []string{}
+	for i, item := range got {
+		if item == nil {
+			missing = append(missing, names[i])
+		} else {
+			matched = append(matched, *item)
+		}
+	}
+	return matched, missing, stats
+}
+
+// labelReconciled labels the batch and asks again for the names the model
+// skipped. Names still missing after [requeueRounds] are quarantined.
+func labelReconciled(ctx context.Context, run func(context.Context, *Question) (*Answer, error), p RetryPolicy, names []string) (*Answer, []FailedName, ReconcileStats, error) {
+	answer := &Answer{}
+	failed := []FailedName{}
+	stats := ReconcileStats{}
+
+	for round := 0; len(names) > 0; round++ {
+		if round > requeueRounds {
+			for _, name := range names {
+				failed = append(failed, FailedName{Name: name, Err: errMissing})
+			}
+			break
+		}
+		if round > 0 {
+			stats.Requeued += len(names)
+		}
+
+		a, f, err := labelBatch(ctx, run, p, names)
+		if err != nil {
+			return nil, nil, stats, err
+		}
+		failed = append(failed, f...)
+
+		// the quarantined names are not expected in the answer
+		asked := names
+		if len(f) > 0 {
+			asked = []string{}
+			skip := map[string]bool{}
+			for _, fn := range f {
+				skip[fn.Name] = true
+			}
+			for _, name := range names {
+				if !skip[name] {
+					asked = append(asked, name)
+				}
+			}
+		}
+
+		matched, missing, s := reconcile(asked, a.Items)
+		stats.Add(s)
+		answer.Items = append(answer.Items, matched...)
+		names = missing
+	}
+
+	return answer, failed, stats, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/firebase/genkit/go/core"
+	"google.golang.org/genai"
+)
+
+// transient errors are worth retrying the same request for
+func transient(err error) bool {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	var gErr *core.GenkitError
+	if errors.As(err, &gErr) {
+		switch gErr.Status {
+		case core.UNAVAILABLE, core.RESOURCE_EXHAUSTED, core.DEADLINE_EXCEEDED,
+			core.ABORTED, core.INTERNAL:
+			return true
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return transport(err)
+}
+
+// transport errors are the failures of reaching the server, such as a
+// refused or reset connection, rather than of the request itself
+func transport(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var urlErr *url.Error
+	var opErr *net.OpError
+	return errors.As(err, &urlErr) || errors.As(err, &opErr)
+}
+
+// fatal errors won't go away by retrying or by splitting the batch, so
+// the run is stopped instead of quarantining every name one by one
+func fatal(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return true
+		}
+	}
+	var gErr *core.GenkitError
+	if errors.As(err, &gErr) {
+		switch gErr.Status {
+		case core.UNAUTHENTICATED, core.PERMISSION_DENIED, core.NOT_FOUND:
+			return true
+		}
+	}
+	return false
+}
+
+type RetryPolicy struct {
+	Attempts  int
+	Base, Max time.Duration
+}
+
+// exponential up to the max, with the half of it randomized to spread the
+// workers hitting the same quota. The doubling stops at the max, as the
+// shifted duration would overflow after a few dozen attempts.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := min(p.Max, p.Base)
+	for range attempt {
+		if d >= p.Max/2 {
+			d = p.Max
+			break
+		}
+		d *= 2
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func (p RetryPolicy) Run(ctx context.Context, run func(context.Context, *Question) (*Answer, error), q *Question) (*Answer, error) {
+	for attempt := 0; ; attempt++ {
+		a, err := run(ctx, q)
+		if err == nil || !transient(err) || attempt+1 >= p.Attempts {
+			return a, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(p.backoff(attempt)):
+		}
+	}
+}
+
+// FailedName is a name that is left out of the labeling because every
+// request containing it failed
+type FailedName struct {
+	Name string
+	Err  error
+}
+
+// labelBatch retries the batch per policy and splits it into halves when
+// it keeps failing, until the names causing the failure are isolated.
+// Only fatal errors are returned, and the transport error every name of
+// the batch failed with, as the server is down rather than the names bad,
+// and quarantining them would keep a resumed run from asking them again.
+func labelBatch(ctx context.Context, run func(context.Context, *Question) (*Answer, error), p RetryPolicy, names []string) (*Answer, []FailedName, error) {
+	a, f, err := splitBatch(ctx, run, p, names)
+	if err != nil || len(f) == 0 || len(f) < len(names) {
+		return a, f, err
+	}
+	for _, fn := range f {
+		if !transport(fn.Err) || fn.Err.Error() != f[0].Err.Error() {
+			return a, f, nil
+		}
+	}
+	return nil, nil, f[0].Err
+}
+
+func splitBatch(ctx context.Context, run func(context.Context, *Question) (*Answer, error), p RetryPolicy, names []string) (*Answer, []FailedName, error) {
+	a, err := p.Run(ctx, run, &Question{MemberNames: names})
+	if err == nil {
+		return a, nil, nil
+	}
+	if fatal(err) || ctx.Err() != nil {
+		return nil, nil, err
+	}
+	if len(names) == 1 {
+		return &Answer{}, []FailedName{{Name: names[0], Err: err}}, nil
+	}
+
+	half := len(names) / 2
+	a1, f1, err := splitBatch(ctx, run, p, names[:half])
+	if err != nil {
+		return nil, nil, err
+	}
+	a2, f2, err := splitBatch(ctx, run, p, names[half:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Answer{Items: append(a1.Items, a2.Items...)}, append(f1, f2...), nil
+}
+
+func (f FailedName) String() string {
+	// error messages might be multiline
+	return fmt.Sprintf("%s\t%s", f.Name, strings.Join(strings.Fields(f.Err.Error()), " "))
+}
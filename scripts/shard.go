@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Shard is the i'th of n partitions of the names. The partition of a name
+// depends only on its canonical form, so the shards labeled on different
+// machines or with different keys don't overlap whatever the input order.
+type Shard struct {
+	I, N int
+}
+
+// ParseShard parses "i/n", eg. "0/4" for the first of 4 shards. An empty
+// string is the single shard of every name.
+func ParseShard(s string) (Shard, error) {
+	if s == "" {
+		return Shard{0, 1}, nil
+	}
+	sh := Shard{}
+	if _, err := fmt.Sscanf(s, "%d/%d", &sh.I, &sh.N); err != nil {
+		return sh, fmt.Errorf("expected i/n: %q", s)
+	}
+	if sh.N < 1 || sh.I < 0 || sh.I >= sh.N {
+		return sh, fmt.Errorf("expected 0 <= i < n: %q", s)
+	}
+	return sh, nil
+}
+
+func (sh Shard) Contains(name string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32()%uint32(sh.N)) == sh.I
+}
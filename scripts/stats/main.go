@@ -0,0 +1,144 @@
+// Computes the ratios of every community under data/ in one pass, and
+// writes them as CSV and JSON for the tables of the README.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"main/internal/labels"
+	"main/internal/members"
+	"main/internal/ratio"
+	"main/internal/stats"
+)
+
+type Args struct {
+	Data         string
+	Male, Female string
+	Overrides    string
+	Level        float64
+	CSV, JSON    string
+}
+
+// number is empty for NaN, as for the share of an empty list
+func number(f stats.Float) string {
+	switch {
+	case math.IsNaN(float64(f)):
+		return ""
+	case math.IsInf(float64(f), 1):
+		return "inf"
+	}
+	return strconv.FormatFloat(float64(f), 'f', 4, 64)
+}
+
+func writeCSV(path string, results []ratio.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{
+		"community", "n", "included", "excluded", "male", "female",
+		"female_share", "female_share_low", "female_share_high",
+		"ratio", "ratio_low", "ratio_high",
+	})
+	for _, r := range results {
+		w.Write([]string{
+			r.Community,
+			strconv.Itoa(r.Members), strconv.Itoa(r.Included), strconv.Itoa(r.Excluded),
+			strconv.Itoa(r.Male), strconv.Itoa(r.Female),
+			number(r.FemaleShare.Value), number(r.FemaleShare.Low), number(r.FemaleShare.High),
+			number(r.Ratio.Value), number(r.Ratio.Low), number(r.Ratio.High),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return f.Close()
+}
+
+func writeJSON(path string, results []ratio.Result) error {
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+func Main() error {
+	args := Args{}
+	flag.StringVar(&args.Data, "data", "data", "directory of the member lists, a file per community")
+	flag.StringVar(&args.Male, "male", "labels/male.txt", "names labeled male")
+	flag.StringVar(&args.Female, "female", "labels/female.txt", "names labeled female")
+	flag.StringVar(&args.Overrides, "overrides", "labels/overrides.tsv", "labels fixed by hand, applied over the labels (empty to skip)")
+	flag.Float64Var(&args.Level, "level", 0.95, "confidence level of the intervals")
+	flag.StringVar(&args.CSV, "csv", "stats.csv", "output for the table of the communities (empty to skip)")
+	flag.StringVar(&args.JSON, "json", "stats.json", "output for the results as JSON (empty to skip)")
+	flag.Parse()
+
+	if args.Level <= 0 || args.Level >= 1 {
+		return fmt.Errorf("expected the level between 0 and 1: %g", args.Level)
+	}
+
+	l, err := labels.ReadFiles(args.Male, args.Female)
+	if err != nil {
+		return fmt.Errorf("reading labels: %w", err)
+	}
+	if args.Overrides != "" {
+		o, err := labels.ReadOverrides(args.Overrides)
+		if err != nil {
+			return fmt.Errorf("reading overrides: %w", err)
+		}
+		l.Apply(o)
+	}
+
+	files, err := filepath.Glob(filepath.Join(args.Data, "*"))
+	if err != nil {
+		return fmt.Errorf("glob: %w", err)
+	}
+	results := []ratio.Result{}
+	for _, file := range files {
+		if fi, err := os.Stat(file); err != nil || fi.IsDir() {
+			continue
+		}
+		names, err := ratio.ReadCommunity(file, l)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", file, err)
+		}
+		r := ratio.Estimate(members.Community(file), names, l, args.Level)
+		results = append(results, r)
+		fmt.Printf("%-32s %5d members %5d included  %s (%s – %s)\n", r.Community, r.Members, r.Included,
+			ratio.FormatRatio(r.Ratio.Value), ratio.FormatRatio(r.Ratio.Low), ratio.FormatRatio(r.Ratio.High))
+	}
+
+	if args.CSV != "" {
+		if err := writeCSV(args.CSV, results); err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+	if args.JSON != "" {
+		if err := writeJSON(args.JSON, results); err != nil {
+			return fmt.Errorf("writing json: %w", err)
+		}
+	}
+	fmt.Printf("%d communities\n", len(results))
+	return nil
+}
+
+func main() {
+	if err := Main(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}